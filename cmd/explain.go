@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/markuskont/go-sigma-rule-engine/pkg/sigma"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// explainCmd represents the explain command
+var explainCmd = &cobra.Command{
+	Use:   "explain",
+	Short: "Show how a sigma rule evaluates a single event",
+	Long: `Evaluate a single sigma rule against a single JSON event and print result of
+every condition node, selection field and keyword pattern. Nodes that rule matching
+would skip because of short circuit evaluation are marked as skipped.
+Exits with non-zero status if rule does not match.`,
+	Run: explain,
+}
+
+func explain(cmd *cobra.Command, args []string) {
+	rule, err := loadRule(viper.GetString("sigma.explain.rule"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(viper.GetString("sigma.explain.event"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	event, err := sigma.NewDynamicMapFromJSON(data)
+	if err != nil {
+		log.Fatal(err)
+	}
+	e := sigma.Explain(rule, event)
+	if viper.GetBool("sigma.explain.json") {
+		out, err := json.MarshalIndent(e, "", "  ")
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(string(out))
+	} else {
+		fmt.Print(e)
+	}
+	if !e.Result {
+		os.Exit(1)
+	}
+}
+
+func init() {
+	sigmaCmd.AddCommand(explainCmd)
+
+	explainCmd.Flags().String("rule", "", "Sigma rule file.")
+	viper.BindPFlag("sigma.explain.rule", explainCmd.Flags().Lookup("rule"))
+
+	explainCmd.Flags().String("event", "", "File with a single JSON event.")
+	viper.BindPFlag("sigma.explain.event", explainCmd.Flags().Lookup("event"))
+
+	explainCmd.Flags().Bool("json", false, "Print trace as JSON instead of text tree.")
+	viper.BindPFlag("sigma.explain.json", explainCmd.Flags().Lookup("json"))
+}
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// graphCmd represents the graph command
+var graphCmd = &cobra.Command{
+	Use:   "graph",
+	Short: "Print sigma rule detection as Graphviz DOT",
+	Long: `Print detection of a single sigma rule as Graphviz digraph, as written in the rule.
+Canonical condition is included as a comment.`,
+	Run: graph,
+}
+
+func graph(cmd *cobra.Command, args []string) {
+	rule, err := loadRule(viper.GetString("sigma.graph.rule"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("// condition: %s\n", rule.Source())
+	fmt.Print(rule.Source().DOT(rule.Title))
+}
+
+func init() {
+	sigmaCmd.AddCommand(graphCmd)
+
+	graphCmd.Flags().String("rule", "", "Sigma rule file.")
+	viper.BindPFlag("sigma.graph.rule", graphCmd.Flags().Lookup("rule"))
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/markuskont/go-sigma-rule-engine/pkg/sigma"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/spf13/cobra"
+)
+
+// lintCmd represents the lint command
+var lintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Validate sigma rules against specification",
+	Long: `Validate every rule in sigma rule directories against sigma specification.
+Reports missing or malformed attributes and rules that cannot be compiled with
+configured pipelines and regex limits.
+Exits with non-zero status if any violations are found.`,
+	Run: lint,
+}
+
+func lint(cmd *cobra.Command, args []string) {
+	c, err := rulesetConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	results, err := sigma.Lint(c)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, res := range results {
+		for _, v := range res.Violations {
+			log.WithFields(log.Fields{
+				"path":  res.Path,
+				"field": v.Field,
+			}).Error(v.Msg)
+		}
+	}
+	log.WithFields(log.Fields{
+		"invalid": len(results),
+	}).Info("Done")
+	if len(results) > 0 {
+		os.Exit(1)
+	}
+}
+
+func init() {
+	sigmaCmd.AddCommand(lintCmd)
+}
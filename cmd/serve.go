@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/markuskont/go-sigma-rule-engine/pkg/sigma"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Match JSON events from stdin and expose engine metrics",
+	Long: `Read newline delimited JSON events from stdin, evaluate them against sigma rules
+and print every match as JSON to stdout. Rules are selected by logsource flags, at
+least one of them is required. Engine and rule metrics are served in
+Prometheus text format on the metrics listen address. Rules are reloaded on SIGHUP,
+a failed reload keeps previous rules. Exits when stdin is closed.`,
+	Run: serve,
+}
+
+// rulesetLoader loads rules from flags and records every attempt in metrics
+// Failed loads keep previously loaded rules
+type rulesetLoader struct {
+	metrics *sigma.Metrics
+	current atomic.Value
+}
+
+func (l *rulesetLoader) load() (*sigma.Ruleset, error) {
+	c, err := rulesetConfig()
+	if err != nil {
+		l.metrics.LoadFailed()
+		return nil, err
+	}
+	c.Metrics = l.metrics
+	r, err := sigma.NewRuleset(c)
+	if err != nil {
+		return nil, err
+	}
+	l.current.Store(r)
+	return r, nil
+}
+
+func (l *rulesetLoader) ruleset() *sigma.Ruleset { return l.current.Load().(*sigma.Ruleset) }
+
+// serveLogsource returns logsource of served events from flags
+// Rules are routed by logsource, so at least one attribute is required for events to match anything
+func serveLogsource() (sigma.Logsource, error) {
+	ls := sigma.Logsource{
+		Product:  viper.GetString("sigma.serve.product"),
+		Category: viper.GetString("sigma.serve.category"),
+		Service:  viper.GetString("sigma.serve.service"),
+	}
+	if ls.Product == "" && ls.Category == "" && ls.Service == "" {
+		return ls, errors.New("no rules apply to events without logsource, set --product, --category or --service")
+	}
+	return ls, nil
+}
+
+// routedRules counts rules that are evaluated for events from logsource
+func routedRules(r *sigma.Ruleset, ls sigma.Logsource) int {
+	n := 0
+	for _, group := range r.Logsources.Get(ls) {
+		n += len(group)
+	}
+	return n
+}
+
+func logLoaded(msg string, r *sigma.Ruleset, ls sigma.Logsource) {
+	routed := routedRules(r, ls)
+	log.WithFields(log.Fields{
+		"ok":          r.Total,
+		"errors":      len(r.Broken),
+		"unsupported": len(r.Unsupported),
+		"routed":      routed,
+	}).Info(msg)
+	if routed == 0 {
+		log.WithFields(log.Fields{
+			"product":  ls.Product,
+			"category": ls.Category,
+			"service":  ls.Service,
+		}).Warn("No loaded rule applies to logsource of events")
+	}
+}
+
+func serve(cmd *cobra.Command, args []string) {
+	ls, err := serveLogsource()
+	if err != nil {
+		log.Fatal(err)
+	}
+	metrics := sigma.NewMetrics()
+	loader := &rulesetLoader{metrics: metrics}
+	r, err := loader.load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	logLoaded("Rules loaded", r, ls)
+
+	if addr := viper.GetString("sigma.serve.metrics.listen"); addr != "" {
+		mux := http.NewServeMux()
+		mux.Handle(viper.GetString("sigma.serve.metrics.path"), metrics)
+		go func() {
+			log.Fatal(http.ListenAndServe(addr, mux))
+		}()
+		log.WithFields(log.Fields{"listen": addr}).Info("Serving metrics")
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			r, err := loader.load()
+			if err != nil {
+				log.WithFields(log.Fields{"error": err}).Error("Reload failed, keeping previous rules")
+				continue
+			}
+			logLoaded("Rules reloaded", r, ls)
+		}
+	}()
+
+	var (
+		scanner = bufio.NewScanner(os.Stdin)
+		out     = json.NewEncoder(os.Stdout)
+	)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		event, err := sigma.NewDynamicMapFromJSON(scanner.Bytes())
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+		results, ok := loader.ruleset().Check(event, ls, false)
+		if !ok {
+			continue
+		}
+		for _, res := range results {
+			if err := out.Encode(res); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func init() {
+	sigmaCmd.AddCommand(serveCmd)
+
+	serveCmd.Flags().String("metrics-listen", ":9145", "Address for Prometheus metrics endpoint. Empty value disables the endpoint.")
+	viper.BindPFlag("sigma.serve.metrics.listen", serveCmd.Flags().Lookup("metrics-listen"))
+
+	serveCmd.Flags().String("metrics-path", "/metrics", "HTTP path of Prometheus metrics endpoint.")
+	viper.BindPFlag("sigma.serve.metrics.path", serveCmd.Flags().Lookup("metrics-path"))
+
+	serveCmd.Flags().String("product", "", "Logsource product of events. At least one of product, category and service is required.")
+	viper.BindPFlag("sigma.serve.product", serveCmd.Flags().Lookup("product"))
+
+	serveCmd.Flags().String("category", "", "Logsource category of events.")
+	viper.BindPFlag("sigma.serve.category", serveCmd.Flags().Lookup("category"))
+
+	serveCmd.Flags().String("service", "", "Logsource service of events.")
+	viper.BindPFlag("sigma.serve.service", serveCmd.Flags().Lookup("service"))
+}
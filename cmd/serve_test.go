@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/markuskont/go-sigma-rule-engine/pkg/sigma"
+	"github.com/spf13/viper"
+)
+
+const serveTestRule = `title: whoami
+id: 5f1abf38-3f4d-4bd6-b8e2-6d4b1d8e0a01
+logsource:
+    product: windows
+detection:
+    selection:
+        CommandLine|contains: whoami
+    condition: selection
+level: high
+`
+
+func TestRulesetLoaderBrokenPipeline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sigma-serve")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "rule.yml"), []byte(serveTestRule), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pipeline := filepath.Join(dir, "pipeline.yaml")
+	if err := ioutil.WriteFile(pipeline, []byte("fieldmappings: [not, a, map"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	viper.Set("sigma.rules.dir", []string{dir})
+	defer viper.Set("sigma.rules.dir", []string{})
+
+	metrics := sigma.NewMetrics()
+	loader := &rulesetLoader{metrics: metrics}
+	loaded, err := loader.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	viper.Set("sigma.pipelines", []string{pipeline})
+	defer viper.Set("sigma.pipelines", []string{})
+	if _, err := loader.load(); err == nil {
+		t.Fatal("reload with broken pipeline should fail")
+	}
+	if loader.ruleset() != loaded {
+		t.Fatal("failed reload should keep previous rules")
+	}
+
+	var out bytes.Buffer
+	if _, err := metrics.WriteTo(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"sigma_ruleset_loads_total 1\n",
+		"sigma_ruleset_load_failures_total 1\n",
+		`sigma_rules{state="loaded"} 1` + "\n",
+	} {
+		if !strings.Contains(out.String(), line) {
+			t.Fatalf("missing %s from\n%s", line, out.String())
+		}
+	}
+}
+
+func TestServeLogsource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sigma-serve")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "rule.yml"), []byte(serveTestRule), 0644); err != nil {
+		t.Fatal(err)
+	}
+	viper.Set("sigma.rules.dir", []string{dir})
+	defer viper.Set("sigma.rules.dir", []string{})
+
+	if _, err := serveLogsource(); err == nil {
+		t.Fatal("serving without logsource flags should fail")
+	}
+
+	loader := &rulesetLoader{metrics: sigma.NewMetrics()}
+	r, err := loader.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer viper.Set("sigma.serve.product", "")
+	for product, routed := range map[string]int{"windows": 1, "linux": 0} {
+		viper.Set("sigma.serve.product", product)
+		ls, err := serveLogsource()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := routedRules(r, ls); n != routed {
+			t.Fatalf("%s: expected %d routed rules, got %d", product, routed, n)
+		}
+	}
+}
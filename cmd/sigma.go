@@ -1,6 +1,9 @@
 package cmd
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/markuskont/go-sigma-rule-engine/pkg/sigma"
 	log "github.com/sirupsen/logrus"
 
@@ -16,13 +19,52 @@ var sigmaCmd = &cobra.Command{
 	Run:   entrypoint,
 }
 
-func entrypoint(cmd *cobra.Command, args []string) {
-	var err error
-	r, err := sigma.NewRuleset(
-		&sigma.Config{
-			Directories: viper.GetStringSlice("sigma.rules.dir"),
+// rulesetConfig builds ruleset configuration from flags shared by sigma subcommands
+func rulesetConfig() (*sigma.Config, error) {
+	dups, err := sigma.ParseDuplicatePolicy(viper.GetString("sigma.rules.duplicates"))
+	if err != nil {
+		return nil, err
+	}
+	pipelines := make([]*sigma.Pipeline, 0)
+	for _, path := range viper.GetStringSlice("sigma.pipelines") {
+		p, err := sigma.NewPipelineFromFile(path)
+		if err != nil {
+			return nil, err
+		}
+		pipelines = append(pipelines, p)
+	}
+	return &sigma.Config{
+		Directories: viper.GetStringSlice("sigma.rules.dir"),
+		Duplicates:  dups,
+		StrictID:    viper.GetBool("sigma.rules.strict_id"),
+		Mappings:    viper.GetStringSlice("sigma.mappings"),
+		Pipelines:   pipelines,
+		RegexLimits: sigma.RegexLimits{
+			MaxLength:  viper.GetInt("sigma.rules.regex.max_length"),
+			MaxProgram: viper.GetInt("sigma.rules.regex.max_program"),
 		},
-	)
+	}, nil
+}
+
+// loadRule loads a single rule with pipelines and regex limits from flags
+func loadRule(path string) (*sigma.Rule, error) {
+	c, err := rulesetConfig()
+	if err != nil {
+		return nil, err
+	}
+	pipelines, err := sigma.ResolvePipelines(c.Mappings, c.Pipelines...)
+	if err != nil {
+		return nil, err
+	}
+	return sigma.LoadRule(path, c.RegexLimits, pipelines...)
+}
+
+func entrypoint(cmd *cobra.Command, args []string) {
+	c, err := rulesetConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	r, err := sigma.NewRuleset(c)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -50,4 +92,25 @@ func init() {
 
 	sigmaCmd.PersistentFlags().StringSlice("sigma-rules-dir", []string{}, "Directories that contains sigma rules.")
 	viper.BindPFlag("sigma.rules.dir", sigmaCmd.PersistentFlags().Lookup("sigma-rules-dir"))
+
+	sigmaCmd.PersistentFlags().String("sigma-rules-duplicates", "ignore", "Handling of rules with identical ID. Options: ignore, reject, keep-first, override. Override lets later directories replace rules from earlier ones.")
+	viper.BindPFlag("sigma.rules.duplicates", sigmaCmd.PersistentFlags().Lookup("sigma-rules-duplicates"))
+
+	sigmaCmd.PersistentFlags().Bool("sigma-rules-strict-id", false, "Reject rules with missing or malformed UUID.")
+	viper.BindPFlag("sigma.rules.strict_id", sigmaCmd.PersistentFlags().Lookup("sigma-rules-strict-id"))
+
+	sigmaCmd.PersistentFlags().Int("sigma-regex-max-length", sigma.DefaultRegexLimits.MaxLength, "Reject rules with longer regular expressions. Negative value disables the check.")
+	viper.BindPFlag("sigma.rules.regex.max_length", sigmaCmd.PersistentFlags().Lookup("sigma-regex-max-length"))
+
+	sigmaCmd.PersistentFlags().Int("sigma-regex-max-program", sigma.DefaultRegexLimits.MaxProgram, "Reject rules with larger total compiled regular expression size. Negative value disables the check.")
+	viper.BindPFlag("sigma.rules.regex.max_program", sigmaCmd.PersistentFlags().Lookup("sigma-regex-max-program"))
+
+	sigmaCmd.PersistentFlags().StringSlice("sigma-pipeline", []string{}, "YAML pipelines that map rule fields and logsources to event schema. Applied in order.")
+	viper.BindPFlag("sigma.pipelines", sigmaCmd.PersistentFlags().Lookup("sigma-pipeline"))
+
+	sigmaCmd.PersistentFlags().StringSlice("sigma-mapping", []string{}, fmt.Sprintf(
+		"Bundled field mappings applied before pipelines. Options: %s. Append @version to pin mapping version.",
+		strings.Join(sigma.BundledPipelines(), ", "),
+	))
+	viper.BindPFlag("sigma.mappings", sigmaCmd.PersistentFlags().Lookup("sigma-mapping"))
 }
@@ -0,0 +1,328 @@
+package sigma
+
+import (
+	"strings"
+	"sync"
+)
+
+// ahoCorasick finds all patterns that occur in a string with a single scan
+// Semantics are equal to calling strings.Contains for every pattern
+type ahoCorasick struct {
+	ids map[string]int32
+	// empty pattern is contained in every string
+	empty int32
+
+	root  [256]int32
+	edges [][]acEdge
+	fail  []int32
+	out   [][]int32
+}
+
+type acEdge struct {
+	b  byte
+	to int32
+}
+
+func newAhoCorasick() *ahoCorasick {
+	return &ahoCorasick{
+		ids:   make(map[string]int32),
+		empty: -1,
+		edges: make([][]acEdge, 1),
+		out:   make([][]int32, 1),
+	}
+}
+
+// add inserts a pattern and returns its ID, identical patterns share the ID
+// build must be called after last pattern is added
+func (a *ahoCorasick) add(pattern string) int32 {
+	if id, ok := a.ids[pattern]; ok {
+		return id
+	}
+	id := int32(len(a.ids))
+	a.ids[pattern] = id
+	if pattern == "" {
+		a.empty = id
+		return id
+	}
+	node := int32(0)
+	for i := 0; i < len(pattern); i++ {
+		next, ok := a.child(node, pattern[i])
+		if !ok {
+			next = int32(len(a.edges))
+			a.edges = append(a.edges, nil)
+			a.out = append(a.out, nil)
+			a.edges[node] = append(a.edges[node], acEdge{b: pattern[i], to: next})
+		}
+		node = next
+	}
+	a.out[node] = append(a.out[node], id)
+	return id
+}
+
+func (a *ahoCorasick) child(node int32, b byte) (int32, bool) {
+	for _, e := range a.edges[node] {
+		if e.b == b {
+			return e.to, true
+		}
+	}
+	return 0, false
+}
+
+// build computes failure links in breadth first order
+func (a *ahoCorasick) build() {
+	a.fail = make([]int32, len(a.edges))
+	for i := range a.root {
+		a.root[i] = 0
+	}
+	queue := make([]int32, 0, len(a.edges))
+	for _, e := range a.edges[0] {
+		a.root[e.b] = e.to
+		queue = append(queue, e.to)
+	}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for _, e := range a.edges[node] {
+			f := a.fail[node]
+			for {
+				if next, ok := a.step(f, e.b); ok || f == 0 {
+					a.fail[e.to] = next
+					break
+				}
+				f = a.fail[f]
+			}
+			// patterns ending at failure node are suffixes of current one
+			a.out[e.to] = append(a.out[e.to], a.out[a.fail[e.to]]...)
+			queue = append(queue, e.to)
+		}
+	}
+}
+
+func (a *ahoCorasick) step(node int32, b byte) (int32, bool) {
+	if node == 0 {
+		next := a.root[b]
+		return next, next != 0
+	}
+	return a.child(node, b)
+}
+
+func (a *ahoCorasick) len() int { return len(a.ids) }
+
+// scan sets bits of every pattern found in s
+func (a *ahoCorasick) scan(s string, hits bitset) {
+	if a.empty >= 0 {
+		hits.set(a.empty)
+	}
+	node := int32(0)
+	for i := 0; i < len(s); i++ {
+		for {
+			if next, ok := a.step(node, s[i]); ok {
+				node = next
+				break
+			}
+			if node == 0 {
+				break
+			}
+			node = a.fail[node]
+		}
+		for _, id := range a.out[node] {
+			hits.set(id)
+		}
+	}
+}
+
+type bitset []uint64
+
+func newBitset(n int) bitset { return make(bitset, (n+63)/64) }
+
+func (b bitset) set(i int32)      { b[i/64] |= 1 << uint(i%64) }
+func (b bitset) has(i int32) bool { return b[i/64]&(1<<uint(i%64)) != 0 }
+
+func (b bitset) reset() {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// literalRef links literals of a leaf to their IDs in a prefilter automaton
+type literalRef struct {
+	pf        *Prefilter
+	automaton int
+	ids       []int32
+}
+
+// prefilterKey identifies an automaton, keyword key is used for event messages instead of a field
+type prefilterKey struct {
+	field   string
+	keyword bool
+	lower   bool
+}
+
+// Prefilter holds Aho-Corasick automatons built from literals of all rules, one for every field
+// Events wrapped with Prefilter.Wrap scan every field value once, and literal patterns of selections and
+// keywords are then evaluated as a bitset lookup
+// Leaves can only be linked to a single prefilter
+type Prefilter struct {
+	keys     map[prefilterKey]int
+	automata []*ahoCorasick
+	lower    []bool
+	pool     sync.Pool
+}
+
+// NewPrefilter builds automatons from literal patterns of all rules and links rule leaves to them
+func NewPrefilter(rules ...Rule) *Prefilter {
+	p := &Prefilter{keys: make(map[prefilterKey]int)}
+	refs := make([]*literalRef, 0)
+	link := func(key prefilterKey, s *stringPatterns) {
+		if len(s.literals) == 0 {
+			return
+		}
+		i, ok := p.keys[key]
+		if !ok {
+			i = len(p.automata)
+			p.keys[key] = i
+			p.automata = append(p.automata, newAhoCorasick())
+			p.lower = append(p.lower, key.lower)
+		}
+		ref := &literalRef{pf: p, automaton: i, ids: make([]int32, len(s.literals))}
+		for j, lit := range s.literals {
+			ref.ids[j] = p.automata[i].add(lit)
+		}
+		s.prefilter = ref
+		refs = append(refs, ref)
+	}
+	for _, rule := range rules {
+		Inspect(rule.tree, func(b Branch) bool {
+			switch n := b.(type) {
+			case *Fields:
+				for field, s := range n.sPatterns {
+					link(prefilterKey{field: field, lower: n.toLower}, &s)
+					n.sPatterns[field] = s
+				}
+			case *Keyword:
+				link(prefilterKey{keyword: true, lower: n.toLower}, &n.stringPatterns)
+			}
+			return true
+		})
+	}
+	for _, a := range p.automata {
+		a.build()
+	}
+	p.pool.New = func() interface{} {
+		e := &PrefilterEvent{
+			pf:   p,
+			hits: make([]bitset, len(p.automata)),
+			done: make([]bool, len(p.automata)),
+		}
+		for i, a := range p.automata {
+			e.hits[i] = newBitset(a.len())
+		}
+		return e
+	}
+	return p
+}
+
+// Wrap returns event that caches scan results, Release should be called when event is no longer used
+func (p *Prefilter) Wrap(obj EventChecker) *PrefilterEvent {
+	e := p.pool.Get().(*PrefilterEvent)
+	e.EventChecker = obj
+	return e
+}
+
+// PrefilterEvent wraps an event with cached automaton scan results
+type PrefilterEvent struct {
+	EventChecker
+
+	pf   *Prefilter
+	hits []bitset
+	done []bool
+}
+
+// Release resets the event and returns it to prefilter pool
+func (e *PrefilterEvent) Release() {
+	for i := range e.done {
+		if e.done[i] {
+			e.hits[i].reset()
+			e.done[i] = false
+		}
+	}
+	e.EventChecker = nil
+	e.pf.pool.Put(e)
+}
+
+func (e *PrefilterEvent) scanned(automaton int) bool {
+	if e.done[automaton] {
+		return false
+	}
+	e.done[automaton] = true
+	return true
+}
+
+// fieldHits scans value of a field only on first call
+func (e *PrefilterEvent) fieldHits(automaton int, val interface{}) bitset {
+	hits := e.hits[automaton]
+	if e.scanned(automaton) {
+		a, lower := e.pf.automata[automaton], e.pf.lower[automaton]
+		var scan func(v interface{})
+		scan = func(v interface{}) {
+			switch cast := v.(type) {
+			case nil:
+			case string:
+				if lower {
+					cast = strings.ToLower(cast)
+				}
+				a.scan(cast, hits)
+			case []string:
+				for _, item := range cast {
+					scan(item)
+				}
+			case []interface{}:
+				for _, item := range cast {
+					scan(item)
+				}
+			default:
+				if str := formatScalar(v); str != "" {
+					scan(str)
+				}
+			}
+		}
+		scan(val)
+	}
+	return hits
+}
+
+// messageHits scans event messages only on first call
+func (e *PrefilterEvent) messageHits(automaton int) bitset {
+	hits := e.hits[automaton]
+	if e.scanned(automaton) {
+		a, lower := e.pf.automata[automaton], e.pf.lower[automaton]
+		for _, msg := range e.GetMessage() {
+			if lower {
+				msg = strings.ToLower(msg)
+			}
+			a.scan(msg, hits)
+		}
+	}
+	return hits
+}
+
+// prefiltered returns literal hits for patterns linked to prefilter of the event
+func (s stringPatterns) prefiltered(obj EventChecker) (*PrefilterEvent, bool) {
+	if s.prefilter == nil {
+		return nil, false
+	}
+	e, ok := obj.(*PrefilterEvent)
+	if !ok || e.pf != s.prefilter.pf {
+		return nil, false
+	}
+	return e, true
+}
+
+func (r literalRef) any(hits bitset) bool {
+	for _, id := range r.ids {
+		if hits.has(id) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+package sigma
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func randomString(r *rand.Rand, alphabet string, max int) string {
+	b := make([]byte, r.Intn(max+1))
+	for i := range b {
+		b[i] = alphabet[r.Intn(len(alphabet))]
+	}
+	return string(b)
+}
+
+func TestAhoCorasick(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		a := newAhoCorasick()
+		patterns := make([]string, 1+r.Intn(30))
+		for j := range patterns {
+			patterns[j] = randomString(r, "abc", 5)
+			a.add(patterns[j])
+		}
+		a.build()
+		for j := 0; j < 50; j++ {
+			s := randomString(r, "abcd", 20)
+			hits := newBitset(a.len())
+			a.scan(s, hits)
+			for _, p := range patterns {
+				if hits.has(a.ids[p]) != strings.Contains(s, p) {
+					t.Fatalf("pattern %q in %q: expected %t", p, s, strings.Contains(s, p))
+				}
+			}
+		}
+	}
+}
+
+var prefilterWords = []string{"whoami", "net user", "mimikatz", "-enc", "iex", "downloadstring", "vssadmin", "delete", "shadows", "who"}
+
+// prefilterTestRules returns rules with literal, glob and regex patterns on fields and keywords
+func prefilterTestRules(t testing.TB, n int) RuleGroup {
+	r := rand.New(rand.NewSource(int64(n)))
+	word := func() string { return prefilterWords[r.Intn(len(prefilterWords))] }
+	rules := make(RuleGroup, 0, n)
+	for i := 0; i < n; i++ {
+		det := Detection{
+			"selection": map[interface{}]interface{}{
+				"CommandLine": []interface{}{word(), word(), fmt.Sprintf("*%s*%s*", word(), word())},
+				"Image":       []interface{}{".exe", word()},
+			},
+			"other":     map[interface{}]interface{}{"Tags": []interface{}{word(), fmt.Sprintf("/%s$/", word())}},
+			"keywords":  []interface{}{word(), word() + " " + word()},
+			"condition": []string{"selection", "selection or keywords", "selection and not other", "other or keywords"}[i%4],
+		}
+		tree, err := ParseDetection(det)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rules = append(rules, Rule{
+			tree:    tree.Optimize(),
+			RawRule: RawRule{ID: fmt.Sprintf("rule-%d", i), Title: fmt.Sprintf("rule %d", i)},
+		})
+	}
+	return rules
+}
+
+func randomPrefilterEvent(r *rand.Rand) messageObject {
+	text := func() string {
+		parts := make([]string, r.Intn(4))
+		for i := range parts {
+			parts[i] = prefilterWords[r.Intn(len(prefilterWords))]
+		}
+		return strings.Join(parts, " ")
+	}
+	e := messageObject{
+		"CommandLine": "powershell " + text(),
+		"Image":       []interface{}{`C:\Windows\cmd.exe`, "bash", text()}[r.Intn(3)],
+		"Tags":        []interface{}{text(), 4, text()},
+		"Message":     text(),
+	}
+	if r.Intn(4) == 0 {
+		delete(e, "CommandLine")
+	}
+	return e
+}
+
+func TestPrefilter(t *testing.T) {
+	rules := prefilterTestRules(t, 200)
+	pf := NewPrefilter(rules...)
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		e := randomPrefilterEvent(r)
+		wrapped := pf.Wrap(e)
+		for _, rule := range rules {
+			if rule.tree.Match(e) != rule.tree.Match(wrapped) {
+				t.Fatalf("%s differs for %+v", rule.tree, e)
+			}
+		}
+		wrapped.Release()
+	}
+	// events from other prefilter fall back to regular matching
+	other := NewPrefilter(prefilterTestRules(t, 10)...).Wrap(randomPrefilterEvent(r))
+	for _, rule := range rules {
+		if rule.tree.Match(other.EventChecker) != rule.tree.Match(other) {
+			t.Fatalf("%s differs for foreign prefilter", rule.tree)
+		}
+	}
+}
+
+func BenchmarkPrefilter(b *testing.B) {
+	// literal only rules, typical for contains modifier on command line
+	r := rand.New(rand.NewSource(1))
+	rules := make(RuleGroup, 0, 2000)
+	for i := 0; i < cap(rules); i++ {
+		patterns := make([]interface{}, 5)
+		for j := range patterns {
+			patterns[j] = randomString(r, "abcdefghijklmnopqrstuvwxyz", 4) + randomString(r, "abcdefghijklmnopqrstuvwxyz -", 8)
+		}
+		tree, err := ParseDetection(Detection{
+			"selection": map[interface{}]interface{}{"CommandLine|contains": patterns},
+			"condition": "selection",
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+		rules = append(rules, Rule{tree: tree})
+	}
+	events := make([]messageObject, 100)
+	for i := range events {
+		events[i] = messageObject{"CommandLine": randomString(r, "abcdefghijklmnopqrstuvwxyz -", 200)}
+	}
+	b.Run("linear", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			rules.Check(events[i%len(events)], false)
+		}
+	})
+	pf := NewPrefilter(rules...)
+	b.Run("prefiltered", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			e := pf.Wrap(events[i%len(events)])
+			rules.Check(e, false)
+			e.Release()
+		}
+	})
+}
@@ -0,0 +1,307 @@
+package sigma
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// AuditdEvent implements EventChecker and LogsourceGetter for Linux audit events
+// Records that share a serial number are combined, fields with different values in multiple records become lists
+// type is the record type, or list of record types for multi-record events
+// Hex encoded values such as proctitle and execve arguments are decoded
+type AuditdEvent struct {
+	Node      string
+	Timestamp string
+	Serial    string
+	Fields    map[string]interface{}
+
+	// Records are raw lines in the order they were received
+	Records []string
+}
+
+// GetLogsource implements LogsourceGetter
+func (a AuditdEvent) GetLogsource() Logsource {
+	return Logsource{Product: "linux", Service: "auditd"}
+}
+
+// GetMessage implements MessageGetter
+func (a AuditdEvent) GetMessage() []string { return a.Records }
+
+// GetField implements SelectionGetter
+func (a AuditdEvent) GetField(key string) (interface{}, bool) {
+	val, ok := a.Fields[key]
+	return val, ok
+}
+
+func (a *AuditdEvent) add(key string, val string) {
+	prev, ok := a.Fields[key]
+	switch {
+	case !ok:
+		a.Fields[key] = val
+	case prev == val:
+	default:
+		if list, ok := prev.([]interface{}); ok {
+			for _, item := range list {
+				if item == val {
+					return
+				}
+			}
+			a.Fields[key] = append(list, val)
+		} else {
+			a.Fields[key] = []interface{}{prev, val}
+		}
+	}
+}
+
+type auditRecord struct {
+	node, typ, timestamp, serial string
+	fields                       [][2]string
+	raw                          string
+}
+
+// fields that auditd hex encodes when they contain spaces or control characters
+var auditEncodedFields = map[string]bool{
+	"proctitle": true,
+	"cmd":       true,
+	"comm":      true,
+	"cwd":       true,
+	"data":      true,
+	"exe":       true,
+	"key":       true,
+	"name":      true,
+	"path":      true,
+}
+
+func parseAuditRecord(line string) (*auditRecord, error) {
+	r := &auditRecord{raw: strings.TrimRight(line, "\r\n")}
+	rest := r.raw
+	if strings.HasPrefix(rest, "node=") {
+		end := strings.IndexByte(rest, ' ')
+		if end < 0 {
+			return nil, fmt.Errorf("invalid audit record %s", line)
+		}
+		r.node, rest = rest[5:end], rest[end+1:]
+	}
+	if !strings.HasPrefix(rest, "type=") {
+		return nil, fmt.Errorf("audit record is missing type: %s", line)
+	}
+	end := strings.IndexByte(rest, ' ')
+	if end < 0 {
+		return nil, fmt.Errorf("audit record is missing header: %s", line)
+	}
+	r.typ, rest = rest[5:end], rest[end+1:]
+	if !strings.HasPrefix(rest, "msg=audit(") {
+		return nil, fmt.Errorf("audit record is missing header: %s", line)
+	}
+	end = strings.Index(rest, "):")
+	if end < 0 {
+		return nil, fmt.Errorf("audit record has invalid header: %s", line)
+	}
+	stamp := rest[len("msg=audit("):end]
+	sep := strings.IndexByte(stamp, ':')
+	if sep < 0 {
+		return nil, fmt.Errorf("audit record has invalid header: %s", line)
+	}
+	r.timestamp, r.serial = stamp[:sep], stamp[sep+1:]
+	r.fields = parseAuditFields(rest[end+2:], r.typ, nil)
+	return r, nil
+}
+
+// parseAuditFields parses key=value pairs, including nested msg='...' and enriched fields after \x1d separator
+func parseAuditFields(s, typ string, out [][2]string) [][2]string {
+	for i := 0; i < len(s); {
+		if s[i] == ' ' || s[i] == '\x1d' {
+			i++
+			continue
+		}
+		eq := strings.IndexByte(s[i:], '=')
+		if eq < 0 {
+			break
+		}
+		key := s[i : i+eq]
+		if sp := strings.LastIndexAny(key, " \x1d"); sp >= 0 {
+			key = key[sp+1:]
+		}
+		i += eq + 1
+		if i >= len(s) {
+			out = append(out, [2]string{key, ""})
+			break
+		}
+		var val string
+		switch s[i] {
+		case '"':
+			end := strings.IndexByte(s[i+1:], '"')
+			if end < 0 {
+				end = len(s) - i - 1
+			}
+			val = s[i+1 : i+1+end]
+			i += end + 2
+			out = append(out, [2]string{key, val})
+		case '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				end = len(s) - i - 1
+			}
+			out = parseAuditFields(s[i+1:i+1+end], typ, out)
+			i += end + 2
+		default:
+			end := strings.IndexAny(s[i:], " \x1d")
+			if end < 0 {
+				end = len(s) - i
+			}
+			val = s[i : i+end]
+			i += end
+			if auditEncodedFields[key] || (typ == "EXECVE" && isExecveArg(key)) {
+				val = decodeAuditHex(val)
+			}
+			out = append(out, [2]string{key, val})
+		}
+	}
+	return out
+}
+
+// isExecveArg reports if key is an argument, aN or aN[i] for chunks of long arguments, but not aN_len
+func isExecveArg(key string) bool {
+	if len(key) < 2 || key[0] != 'a' {
+		return false
+	}
+	i := 1 + countDigits(key[1:])
+	switch {
+	case i == 1:
+		return false
+	case i == len(key):
+		return true
+	case key[i] != '[' || key[len(key)-1] != ']':
+		return false
+	}
+	chunk := key[i+1 : len(key)-1]
+	return len(chunk) > 0 && countDigits(chunk) == len(chunk)
+}
+
+func countDigits(s string) int {
+	n := 0
+	for n < len(s) && s[n] >= '0' && s[n] <= '9' {
+		n++
+	}
+	return n
+}
+
+// decodeAuditHex decodes hex encoded value, arguments separated by null bytes are joined with spaces
+func decodeAuditHex(val string) string {
+	if len(val) < 2 || len(val)%2 != 0 {
+		return val
+	}
+	for _, c := range val {
+		if !(c >= '0' && c <= '9' || c >= 'A' && c <= 'F') {
+			return val
+		}
+	}
+	data, err := hex.DecodeString(val)
+	if err != nil {
+		return val
+	}
+	return strings.TrimRight(strings.Replace(string(data), "\x00", " ", -1), " ")
+}
+
+// AuditdAssembler groups audit records into events by serial number
+// Event is complete when EOE record is received, or when more than MaxPending events are incomplete
+// The latter covers single record events that are not terminated by EOE
+type AuditdAssembler struct {
+	MaxPending int
+
+	pending map[string]*AuditdEvent
+	order   []string
+}
+
+// NewAuditdAssembler creates assembler with room for 8 incomplete events
+func NewAuditdAssembler() *AuditdAssembler {
+	return &AuditdAssembler{
+		MaxPending: 8,
+		pending:    make(map[string]*AuditdEvent),
+		order:      make([]string, 0),
+	}
+}
+
+// Add parses a single audit record and returns any events completed by it
+func (a *AuditdAssembler) Add(line string) ([]*AuditdEvent, error) {
+	r, err := parseAuditRecord(line)
+	if err != nil {
+		return nil, err
+	}
+	id := r.node + ":" + r.serial
+	if r.typ == "EOE" {
+		if e, ok := a.pending[id]; ok {
+			a.remove(id)
+			return []*AuditdEvent{e}, nil
+		}
+		return nil, nil
+	}
+	e, ok := a.pending[id]
+	if !ok {
+		e = &AuditdEvent{
+			Node:      r.node,
+			Timestamp: r.timestamp,
+			Serial:    r.serial,
+			Fields:    make(map[string]interface{}),
+		}
+		a.pending[id] = e
+		a.order = append(a.order, id)
+	}
+	e.Records = append(e.Records, r.raw)
+	e.add("type", r.typ)
+	for _, kv := range r.fields {
+		e.add(kv[0], kv[1])
+	}
+	out := make([]*AuditdEvent, 0)
+	for len(a.order) > a.MaxPending {
+		oldest := a.order[0]
+		out = append(out, a.pending[oldest])
+		a.remove(oldest)
+	}
+	return out, nil
+}
+
+// Flush returns all incomplete events
+func (a *AuditdAssembler) Flush() []*AuditdEvent {
+	out := make([]*AuditdEvent, 0, len(a.order))
+	for _, id := range a.order {
+		out = append(out, a.pending[id])
+	}
+	a.pending = make(map[string]*AuditdEvent)
+	a.order = a.order[:0]
+	return out
+}
+
+func (a *AuditdAssembler) remove(id string) {
+	delete(a.pending, id)
+	for i, item := range a.order {
+		if item == id {
+			a.order = append(a.order[:i], a.order[i+1:]...)
+			return
+		}
+	}
+}
+
+// ReadAuditd assembles events from audit log stream and passes them to fn
+// Records that cannot be parsed are skipped, incomplete events are flushed at the end of stream
+func ReadAuditd(r io.Reader, fn func(*AuditdEvent)) error {
+	a := NewAuditdAssembler()
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		events, err := a.Add(scanner.Text())
+		if err != nil {
+			continue
+		}
+		for _, e := range events {
+			fn(e)
+		}
+	}
+	for _, e := range a.Flush() {
+		fn(e)
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,97 @@
+package sigma
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var auditdLog = `type=SYSCALL msg=audit(1579168800.123:4242): arch=c000003e syscall=59 success=yes exit=0 a0=55d0 a1=55d1 ppid=1000 pid=1001 auid=1000 uid=0 comm="cat" exe="/usr/bin/cat" key="susp_activity"` + "\x1d" + `ARCH=x86_64 SYSCALL=execve UID="root"
+type=EXECVE msg=audit(1579168800.123:4242): argc=4 a0="cat" a1="/etc/shadow" a2=2F746D702F66696C652077697468207370616365 a3_len=1048 a3[0]=6869 a3[1]=7468657265
+type=CWD msg=audit(1579168800.123:4242): cwd="/root"
+type=PATH msg=audit(1579168800.123:4242): item=0 name="/usr/bin/cat" inode=1 nametype=NORMAL
+type=PATH msg=audit(1579168800.123:4242): item=1 name="/etc/shadow" inode=2 nametype=NORMAL
+type=PROCTITLE msg=audit(1579168800.123:4242): proctitle=636174002F6574632F736861646F77
+type=EOE msg=audit(1579168800.123:4242):
+type=CONFIG_CHANGE msg=audit(1579168802.000:4244): op=set audit_backlog_limit=8192 old=64 auid=1000 ses=3 res=1
+type=CONFIG_CHANGE msg=audit(1579168802.000:4245): op=set audit_failure=2 old=1 new=8192 auid=1000 ses=3 res=1
+type=USER_LOGIN msg=audit(1579168801.000:4243): pid=2000 uid=0 auid=1000 ses=3 msg='op=login id=1000 exe="/usr/sbin/sshd" hostname=10.0.0.5 addr=10.0.0.5 terminal=/dev/pts/0 res=success'
+this line is not an audit record
+`
+
+func TestAuditd(t *testing.T) {
+	events := make([]*AuditdEvent, 0)
+	if err := ReadAuditd(strings.NewReader(auditdLog), func(e *AuditdEvent) {
+		events = append(events, e)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 4 {
+		t.Fatalf("expected 4 events, got %d", len(events))
+	}
+	e := events[0]
+	if e.Serial != "4242" || e.Timestamp != "1579168800.123" || len(e.Records) != 6 {
+		t.Fatalf("wrong event header %+v", e)
+	}
+	for k, v := range map[string]interface{}{
+		"type":      []interface{}{"SYSCALL", "EXECVE", "CWD", "PATH", "PROCTITLE"},
+		"syscall":   "59",
+		"SYSCALL":   "execve",
+		"UID":       "root",
+		"exe":       "/usr/bin/cat",
+		"key":       "susp_activity",
+		"a0":        []interface{}{"55d0", "cat"},
+		"a1":        []interface{}{"55d1", "/etc/shadow"},
+		"a2":        "/tmp/file with space",
+		"a3_len":    "1048",
+		"a3[0]":     "hi",
+		"a3[1]":     "there",
+		"cwd":       "/root",
+		"name":      []interface{}{"/usr/bin/cat", "/etc/shadow"},
+		"proctitle": "cat /etc/shadow",
+	} {
+		if val, ok := e.GetField(k); !ok || !reflect.DeepEqual(val, v) {
+			t.Fatalf("%s: expected %#v, got %#v", k, v, val)
+		}
+	}
+	if ls := e.GetLogsource(); ls.Product != "linux" || ls.Service != "auditd" {
+		t.Fatalf("wrong logsource %+v", ls)
+	}
+
+	for i, fields := range []map[string]interface{}{
+		{"audit_backlog_limit": "8192", "old": "64"},
+		{"audit_failure": "2", "old": "1", "new": "8192"},
+	} {
+		for k, v := range fields {
+			if val, ok := events[i+1].GetField(k); !ok || !reflect.DeepEqual(val, v) {
+				t.Fatalf("config change %d %s: expected %#v, got %#v", i, k, v, val)
+			}
+		}
+	}
+
+	e = events[3]
+	for k, v := range map[string]interface{}{
+		"type":     "USER_LOGIN",
+		"op":       "login",
+		"exe":      "/usr/sbin/sshd",
+		"hostname": "10.0.0.5",
+		"res":      "success",
+	} {
+		if val, ok := e.GetField(k); !ok || !reflect.DeepEqual(val, v) {
+			t.Fatalf("%s: expected %#v, got %#v", k, v, val)
+		}
+	}
+
+	a := NewAuditdAssembler()
+	a.MaxPending = 1
+	if out, _ := a.Add(`type=USER_CMD msg=audit(1.0:1): cmd=6C73`); len(out) != 0 {
+		t.Fatal("event completed too early")
+	}
+	out, _ := a.Add(`type=USER_CMD msg=audit(1.0:2): cmd=6C73`)
+	if len(out) != 1 || out[0].Fields["cmd"] != "ls" {
+		t.Fatalf("oldest event should be completed when too many are pending, got %+v", out)
+	}
+	if _, err := a.Add(`type=SYSCALL arch=c000003e`); err == nil {
+		t.Fatal("record without header should return error")
+	}
+}
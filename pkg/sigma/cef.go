@@ -0,0 +1,244 @@
+package sigma
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CEFEvent implements EventChecker for ArcSight Common Event Format
+// Header is exposed as cefVersion, deviceVendor, deviceProduct, deviceVersion, deviceEventClassId, name and severity
+// Extension keys are exposed verbatim, custom fields are also exposed under their label, e.g. cs1Label=Policy cs1=x as Policy
+type CEFEvent struct {
+	Fields map[string]interface{}
+
+	// Raw is the CEF payload without any preceding syslog header
+	Raw string
+}
+
+var cefHeader = []string{
+	"cefVersion",
+	"deviceVendor",
+	"deviceProduct",
+	"deviceVersion",
+	"deviceEventClassId",
+	"name",
+	"severity",
+}
+
+// ParseCEF parses a CEF record, optionally preceded by syslog header
+func ParseCEF(line string) (*CEFEvent, error) {
+	start := strings.Index(line, "CEF:")
+	if start < 0 {
+		return nil, fmt.Errorf("missing CEF header in %s", line)
+	}
+	line = strings.TrimRight(line[start:], "\r\n")
+	header, rest, err := splitHeader(strings.TrimPrefix(line, "CEF:"), '|', len(cefHeader))
+	if err != nil {
+		return nil, fmt.Errorf("CEF %s", err)
+	}
+	e := &CEFEvent{
+		Fields: make(map[string]interface{}),
+		Raw:    line,
+	}
+	for i, key := range cefHeader {
+		e.Fields[key] = numOrString(header[i])
+	}
+	ext := parseCEFExtension(rest)
+	for key, val := range ext {
+		e.Fields[key] = val
+	}
+	for key, label := range ext {
+		if !strings.HasSuffix(key, "Label") || label == "" {
+			continue
+		}
+		if val, ok := ext[strings.TrimSuffix(key, "Label")]; ok {
+			if _, exists := e.Fields[label]; !exists {
+				e.Fields[label] = val
+			}
+		}
+	}
+	return e, nil
+}
+
+// splitHeader splits n pipe separated header fields with \| and \\ escapes and returns the remainder
+func splitHeader(line string, sep byte, n int) ([]string, string, error) {
+	out := make([]string, 0, n)
+	var b strings.Builder
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		if c == '\\' && i+1 < len(line) && (line[i+1] == sep || line[i+1] == '\\') {
+			b.WriteByte(line[i+1])
+			i++
+			continue
+		}
+		if c == sep {
+			out = append(out, b.String())
+			b.Reset()
+			if len(out) == n {
+				return out, line[i+1:], nil
+			}
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return out, "", fmt.Errorf("header has %d fields, expected %d", len(out), n)
+}
+
+// parseCEFExtension parses space separated key=value pairs where values may contain spaces
+// Values support \= \\ \n and \r escapes
+func parseCEFExtension(ext string) map[string]string {
+	out := make(map[string]string)
+	// positions of unescaped equal signs
+	eqs := make([]int, 0)
+	for i := 0; i < len(ext); i++ {
+		switch ext[i] {
+		case '\\':
+			i++
+		case '=':
+			eqs = append(eqs, i)
+		}
+	}
+	keyStart := func(eq, floor int) int {
+		i := strings.LastIndexByte(ext[floor:eq], ' ')
+		if i < 0 {
+			return floor
+		}
+		return floor + i + 1
+	}
+	floor := 0
+	for n, eq := range eqs {
+		start := keyStart(eq, floor)
+		key := ext[start:eq]
+		end := len(ext)
+		if n+1 < len(eqs) {
+			end = keyStart(eqs[n+1], eq+1) - 1
+			if end < eq+1 {
+				end = eq + 1
+			}
+		}
+		if key != "" {
+			out[key] = unescapeCEF(strings.TrimRight(ext[eq+1:end], " "))
+		}
+		floor = end
+	}
+	return out
+}
+
+func unescapeCEF(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			default:
+				b.WriteByte(s[i])
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// GetMessage implements MessageGetter
+func (e CEFEvent) GetMessage() []string { return []string{e.Raw} }
+
+// GetField implements SelectionGetter
+func (e CEFEvent) GetField(key string) (interface{}, bool) {
+	val, ok := e.Fields[key]
+	return val, ok
+}
+
+// LEEFEvent implements EventChecker for IBM QRadar Log Event Extended Format 1.0 and 2.0
+// Header is exposed as leefVersion, vendor, product, version and eventId, attributes are exposed verbatim
+type LEEFEvent struct {
+	Fields map[string]interface{}
+
+	// Raw is the LEEF payload without any preceding syslog header
+	Raw string
+}
+
+var leefHeader = []string{
+	"leefVersion",
+	"vendor",
+	"product",
+	"version",
+	"eventId",
+}
+
+// ParseLEEF parses a LEEF record, optionally preceded by syslog header
+func ParseLEEF(line string) (*LEEFEvent, error) {
+	start := strings.Index(line, "LEEF:")
+	if start < 0 {
+		return nil, fmt.Errorf("missing LEEF header in %s", line)
+	}
+	line = strings.TrimRight(line[start:], "\r\n")
+	header, rest, err := splitHeader(strings.TrimPrefix(line, "LEEF:"), '|', len(leefHeader))
+	if err != nil {
+		return nil, fmt.Errorf("LEEF %s", err)
+	}
+	e := &LEEFEvent{
+		Fields: make(map[string]interface{}),
+		Raw:    line,
+	}
+	for i, key := range leefHeader {
+		e.Fields[key] = header[i]
+	}
+	delim := "\t"
+	if strings.HasPrefix(header[0], "2") {
+		// LEEF 2.0 defines attribute delimiter as a character or its hex code
+		i := strings.IndexByte(rest, '|')
+		if i < 0 {
+			return nil, fmt.Errorf("LEEF 2.0 header is missing delimiter")
+		}
+		if d := rest[:i]; d != "" {
+			if delim, err = parseLEEFDelimiter(d); err != nil {
+				return nil, err
+			}
+		}
+		rest = rest[i+1:]
+	}
+	for _, attr := range strings.Split(rest, delim) {
+		if i := strings.IndexByte(attr, '='); i > 0 {
+			e.Fields[attr[:i]] = attr[i+1:]
+		}
+	}
+	return e, nil
+}
+
+func parseLEEFDelimiter(d string) (string, error) {
+	if len(d) == 1 {
+		return d, nil
+	}
+	hex := strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(d), "0"), "x")
+	n, err := strconv.ParseUint(hex, 16, 8)
+	if err != nil {
+		return "", fmt.Errorf("invalid LEEF delimiter %s", d)
+	}
+	return string(rune(n)), nil
+}
+
+// GetMessage implements MessageGetter
+func (e LEEFEvent) GetMessage() []string { return []string{e.Raw} }
+
+// GetField implements SelectionGetter
+func (e LEEFEvent) GetField(key string) (interface{}, bool) {
+	val, ok := e.Fields[key]
+	return val, ok
+}
+
+// numOrString converts integer strings to int so that they match numeric rule patterns
+func numOrString(s string) interface{} {
+	if n, err := strconv.Atoi(s); err == nil {
+		return n
+	}
+	return s
+}
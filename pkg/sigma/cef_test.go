@@ -0,0 +1,77 @@
+package sigma
+
+import (
+	"testing"
+)
+
+func TestCEF(t *testing.T) {
+	line := `Jan 16 10:00:00 proxy01 CEF:0|Security|threatmanager|1.0|100|worm successfully stopped|10|src=10.0.0.1 dst=2.1.2.2 spt=1232 request=http://example.com/a b?c\=d msg=line one\nline two with \\ and \| cs1Label=Policy Name cs1=Block All act=blocked`
+	e, err := ParseCEF(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range map[string]interface{}{
+		"cefVersion":         0,
+		"deviceVendor":       "Security",
+		"deviceProduct":      "threatmanager",
+		"deviceVersion":      "1.0",
+		"deviceEventClassId": 100,
+		"name":               "worm successfully stopped",
+		"severity":           10,
+		"src":                "10.0.0.1",
+		"dst":                "2.1.2.2",
+		"spt":                "1232",
+		"request":            "http://example.com/a b?c=d",
+		"msg":                "line one\nline two with \\ and |",
+		"cs1":                "Block All",
+		"Policy Name":        "Block All",
+		"act":                "blocked",
+	} {
+		if val, ok := e.GetField(k); !ok || val != v {
+			t.Fatalf("%s: expected %#v, got %#v", k, v, val)
+		}
+	}
+	e, err = ParseCEF(`CEF:0|Vendor \| Inc|Product \\ X|1|sig|Name|High|`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Fields["deviceVendor"] != "Vendor | Inc" || e.Fields["deviceProduct"] != `Product \ X` || e.Fields["severity"] != "High" {
+		t.Fatalf("wrong header escaping %+v", e.Fields)
+	}
+	if _, err := ParseCEF(`CEF:0|Vendor|Product`); err == nil {
+		t.Fatal("truncated header should return error")
+	}
+	if _, err := ParseCEF(`LEEF:1.0|Vendor|Product|1|2|`); err == nil {
+		t.Fatal("missing CEF header should return error")
+	}
+}
+
+func TestLEEF(t *testing.T) {
+	for _, line := range []string{
+		"LEEF:1.0|Microsoft|MSExchange|4.0 SP1|15345|src=192.0.2.0\tdst=172.50.123.1\tsev=5\tcat=anomaly\tusrName=joe.black\tcmd=a=b",
+		"<13>Jan 16 10:00:00 fw01 LEEF:2.0|Microsoft|MSExchange|4.0 SP1|15345|^|src=192.0.2.0^dst=172.50.123.1^sev=5^cat=anomaly^usrName=joe.black^cmd=a=b",
+		"LEEF:2.0|Microsoft|MSExchange|4.0 SP1|15345|x7C|src=192.0.2.0|dst=172.50.123.1|sev=5|cat=anomaly|usrName=joe.black|cmd=a=b",
+	} {
+		e, err := ParseLEEF(line)
+		if err != nil {
+			t.Fatalf("%s: %s", line, err)
+		}
+		for k, v := range map[string]interface{}{
+			"vendor":  "Microsoft",
+			"product": "MSExchange",
+			"version": "4.0 SP1",
+			"eventId": "15345",
+			"src":     "192.0.2.0",
+			"dst":     "172.50.123.1",
+			"usrName": "joe.black",
+			"cmd":     "a=b",
+		} {
+			if val, ok := e.GetField(k); !ok || val != v {
+				t.Fatalf("%s: %s expected %#v, got %#v", line, k, v, val)
+			}
+		}
+	}
+	if _, err := ParseLEEF("LEEF:2.0|Microsoft|MSExchange|4.0 SP1|15345|xZZ|src=1"); err == nil {
+		t.Fatal("invalid delimiter should return error")
+	}
+}
@@ -17,16 +17,26 @@ func (i Item) String() string { return i.Val }
 
 // TODO - perhaps we should invoke parse only if we actually need to parse the query statement and simply instantiate a single-branch rule otherwise
 func ParseDetection(s Detection) (*Tree, error) {
+	return parseDetection(s, RuleConfig{})
+}
+
+// ParseDetectionWithLimits parses detection like ParseDetection, but rejects regular expressions that exceed
+// limits with ErrRegexLimit before they are compiled
+func ParseDetectionWithLimits(s Detection, limits RegexLimits) (*Tree, error) {
+	return parseDetection(s, RuleConfig{regex: newRegexBudget(limits)})
+}
+
+func parseDetection(s Detection, c RuleConfig) (*Tree, error) {
 	if s == nil {
 		return nil, ErrMissingDetection{}
 	}
 	if len(s) < 3 {
-		return parseSimpleScenario(s)
+		return parseSimpleScenario(s, c)
 	}
-	return parseComplexScenario(s)
+	return parseComplexScenario(s, c)
 }
 
-func parseSimpleScenario(s Detection) (*Tree, error) {
+func parseSimpleScenario(s Detection, c RuleConfig) (*Tree, error) {
 	switch len(s) {
 	case 1:
 		// Simple case - should have only one search field, but should not have a condition field
@@ -53,7 +63,7 @@ func parseSimpleScenario(s Detection) (*Tree, error) {
 	rx := s.Fields()
 	ast := &Tree{}
 	r := <-rx
-	root, err := newRuleMatcherFromIdent(&r, false)
+	root, err := newRuleMatcherFromIdent(&r, c.LowerCase, c.regex)
 	if err != nil {
 		return nil, err
 	}
@@ -61,7 +71,7 @@ func parseSimpleScenario(s Detection) (*Tree, error) {
 	return ast, nil
 }
 
-func parseComplexScenario(s Detection) (*Tree, error) {
+func parseComplexScenario(s Detection, c RuleConfig) (*Tree, error) {
 	// Complex case, time to build syntax tree out of condition statement
 	raw, ok := s["condition"].(string)
 	if !ok {
@@ -73,6 +83,7 @@ func parseComplexScenario(s Detection) (*Tree, error) {
 		tokens:    make([]Item, 0),
 		previous:  TokBegin,
 		condition: raw,
+		config:    c,
 	}
 	if err := p.run(); err != nil {
 		return nil, err
@@ -80,17 +91,35 @@ func parseComplexScenario(s Detection) (*Tree, error) {
 	return &Tree{Root: p.result}, nil
 }
 
-func newRuleMatcherFromIdent(v *SearchExpr, toLower bool) (Branch, error) {
+func newRuleMatcherFromIdent(v *SearchExpr, toLower bool, budget *regexBudget) (Branch, error) {
+	b, err := newRuleMatcher(v, toLower, budget)
+	if err != nil {
+		return b, err
+	}
+	switch leaf := b.(type) {
+	case *Keyword:
+		leaf.ident = v.Name
+	case *Fields:
+		leaf.ident = v.Name
+	case FieldsList:
+		for _, f := range leaf {
+			f.ident = v.Name
+		}
+	}
+	return b, nil
+}
+
+func newRuleMatcher(v *SearchExpr, toLower bool, budget *regexBudget) (Branch, error) {
 	if v == nil {
 		return nil, fmt.Errorf("Missing rule search expression")
 	}
 	switch v.Type {
 	case ExprKeywords:
-		return NewKeywordFromInterface(toLower, v.Content)
+		return newKeywordFromInterface(toLower, v.Content, budget)
 	case ExprSelection:
 		switch m := v.Content.(type) {
 		case map[string]interface{}:
-			return NewFields(m, toLower, false)
+			return newFields(m, toLower, true, budget)
 		case []interface{}:
 			// might be a list of selections where each entry is a distinct selection rule joined by logical OR
 			branch := make(FieldsList, 0)
@@ -105,9 +134,9 @@ func newRuleMatcherFromIdent(v *SearchExpr, toLower bool) (Branch, error) {
 					if err != nil {
 						return nil, err
 					}
-					elem, err = NewFields(m2, toLower, false)
+					elem, err = newFields(m2, toLower, true, budget)
 				case map[string]interface{}:
-					elem, err = NewFields(expr, toLower, false)
+					elem, err = newFields(expr, toLower, true, budget)
 				default:
 					return nil, fmt.Errorf("Unhandled rule search expression type")
 				}
@@ -122,7 +151,7 @@ func newRuleMatcherFromIdent(v *SearchExpr, toLower bool) (Branch, error) {
 			if err != nil {
 				return nil, err
 			}
-			return NewFields(m2, toLower, false)
+			return newFields(m2, toLower, true, budget)
 		default:
 			return nil, fmt.Errorf(
 				"selection rule %s should be defined as a map, got %s",
@@ -181,7 +210,7 @@ func newBranchFromGroup(group tokensHandler, detect Detection, c RuleConfig) (Br
 				for i, item := range regular {
 					switch t := item.T; {
 					case t == Identifier:
-						r, err := newRuleMatcherFromIdent(detect.Get(item.Val), c.LowerCase)
+						r, err := newRuleMatcherFromIdent(detect.Get(item.Val), c.LowerCase, c.regex)
 						if err != nil {
 							return nil, err
 						}
@@ -193,9 +222,9 @@ func newBranchFromGroup(group tokensHandler, detect Detection, c RuleConfig) (Br
 						}())
 					}
 				}
-				// move offset to group end
-				offset = pos.To
 			}
+			// move offset to group end
+			offset = pos.To
 
 			b, err := parseSearch(sub, detect, c, false)
 			if err != nil {
@@ -213,7 +242,7 @@ func newBranchFromGroup(group tokensHandler, detect Detection, c RuleConfig) (Br
 					for i, item := range regular {
 						switch t := item.T; {
 						case t == Identifier:
-							r, err := newRuleMatcherFromIdent(detect.Get(item.Val), c.LowerCase)
+							r, err := newRuleMatcherFromIdent(detect.Get(item.Val), c.LowerCase, c.regex)
 							if err != nil {
 								return nil, err
 							}
@@ -239,7 +268,7 @@ func newBranchFromGroup(group tokensHandler, detect Detection, c RuleConfig) (Br
 		case 2:
 			ident = group.tokens[1]
 		}
-		r, err := newRuleMatcherFromIdent(detect.Get(ident.Val), c.LowerCase)
+		r, err := newRuleMatcherFromIdent(detect.Get(ident.Val), c.LowerCase, c.regex)
 		return func() Branch {
 			if group.isNegated() {
 				return NodeNot{Branch: r}
@@ -266,7 +295,7 @@ func parseSimpleSearch(t tokens, detect Detection, c RuleConfig) (Branch, error)
 			case 2:
 				ident = group.tokens[1]
 			}
-			r, err := newRuleMatcherFromIdent(detect.Get(ident.Val), c.LowerCase)
+			r, err := newRuleMatcherFromIdent(detect.Get(ident.Val), c.LowerCase, c.regex)
 			if err != nil {
 				return nil, err
 			}
@@ -281,7 +310,7 @@ func parseSimpleSearch(t tokens, detect Detection, c RuleConfig) (Branch, error)
 			for i, item := range group.tokens {
 				switch item.T {
 				case Identifier:
-					r, err := newRuleMatcherFromIdent(detect.Get(item.Val), c.LowerCase)
+					r, err := newRuleMatcherFromIdent(detect.Get(item.Val), c.LowerCase, c.regex)
 					if err != nil {
 						return nil, err
 					}
@@ -322,6 +351,8 @@ type parser struct {
 
 	// resulting rule that can be collected later
 	result Branch
+
+	config RuleConfig
 }
 
 func (p *parser) run() error {
@@ -333,7 +364,7 @@ func (p *parser) run() error {
 		return err
 	}
 	// Pass 2: find groups
-	b, err := parseSearch(p.tokens, p.sigma, RuleConfig{}, true)
+	b, err := parseSearch(p.tokens, p.sigma, p.config, true)
 	if err != nil {
 		return err
 	}
@@ -660,18 +691,11 @@ func (t tokens) splitByToken(tok Token) []*tokensHandler {
 		if (item.T == tok && groupBalance == 0) || pos == last {
 			switch pos {
 			case last:
-				rules = append(rules, func() *tokensHandler {
-					if last > 0 && t[pos-1].T == KeywordNot {
-						return &tokensHandler{
-							tokens:      t[pos-1:],
-							hasSubGroup: hasSubGroup,
-						}
-					}
-					return &tokensHandler{
-						tokens:      t[start:],
-						hasSubGroup: hasSubGroup,
-					}
-				}().discoverSubGroups())
+				g := &tokensHandler{
+					tokens:      t[start:],
+					hasSubGroup: hasSubGroup,
+				}
+				rules = append(rules, g.discoverSubGroups())
 			default:
 				g := &tokensHandler{
 					tokens:      t[start:pos],
@@ -413,3 +413,54 @@ var invalidConditions = []string{
 	"all of 1 of",
 	"or and)",
 }
+
+var regressionDetection = map[string]interface{}{
+	"a": map[string]interface{}{"A": "a"},
+	"b": map[string]interface{}{"B": "b"},
+	"c": map[string]interface{}{"C": "c"},
+	"d": map[string]interface{}{"D": "d"},
+}
+
+func TestParseRegressions(t *testing.T) {
+	for _, c := range []struct {
+		condition          string
+		positive, negative []map[string]string
+	}{
+		{
+			// last OR group ending in negation was cut down to "not c", parsed as "a or not c"
+			condition: "a or b and not c",
+			positive:  []map[string]string{{"A": "a"}, {"B": "b"}, {"A": "a", "C": "c"}},
+			negative:  []map[string]string{{}, {"B": "b", "C": "c"}},
+		},
+		{
+			// identifiers of a leading group were added again before the next group, parsed as "a and b and (a or b) and (c or d)"
+			condition: "(a or b) and (c or d)",
+			positive:  []map[string]string{{"A": "a", "C": "c"}, {"B": "b", "D": "d"}},
+			negative:  []map[string]string{{"A": "a"}, {"C": "c", "D": "d"}},
+		},
+		{
+			condition: "(a or b) and not (c or d)",
+			positive:  []map[string]string{{"A": "a"}, {"B": "b"}},
+			negative:  []map[string]string{{"A": "a", "D": "d"}, {"C": "c"}},
+		},
+	} {
+		detection := Detection{"condition": c.condition}
+		for k, v := range regressionDetection {
+			detection[k] = v
+		}
+		tree, err := ParseDetection(detection)
+		if err != nil {
+			t.Fatalf("%s: %s", c.condition, err)
+		}
+		for _, obj := range c.positive {
+			if !tree.Match(dummyObject2(obj)) {
+				t.Fatalf("%s: %+v should match", c.condition, obj)
+			}
+		}
+		for _, obj := range c.negative {
+			if tree.Match(dummyObject2(obj)) {
+				t.Fatalf("%s: %+v should not match", c.condition, obj)
+			}
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package sigma
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// CSVReader turns rows of a CSV or TSV export into events
+// First row is used as header, column names can be changed with Rename
+type CSVReader struct {
+	// Rename maps header names to field names used by rules
+	Rename map[string]string
+	// InferTypes converts numeric cells to float64 so numeric patterns can match them
+	InferTypes bool
+	// MessageFields are used for keyword rules, see DynamicMap
+	MessageFields []string
+
+	reader *csv.Reader
+	header []string
+}
+
+// NewCSVReader wraps a comma separated stream
+func NewCSVReader(r io.Reader) *CSVReader {
+	return newCSVReader(r, ',')
+}
+
+// NewTSVReader wraps a tab separated stream, quotes are not required to be balanced
+func NewTSVReader(r io.Reader) *CSVReader {
+	c := newCSVReader(r, '\t')
+	c.reader.LazyQuotes = true
+	return c
+}
+
+func newCSVReader(r io.Reader, comma rune) *CSVReader {
+	reader := csv.NewReader(r)
+	reader.Comma = comma
+	reader.ReuseRecord = true
+	return &CSVReader{reader: reader, InferTypes: true}
+}
+
+// Header returns column names after renaming, nil before first call to Next
+func (c *CSVReader) Header() []string { return c.header }
+
+// Next returns the next row, io.EOF is returned when stream is exhausted
+// Columns with empty header are skipped
+func (c *CSVReader) Next() (*DynamicMap, error) {
+	if c.header == nil {
+		record, err := c.reader.Read()
+		if err != nil {
+			return nil, err
+		}
+		c.header = make([]string, len(record))
+		for i, name := range record {
+			if i == 0 {
+				// spreadsheet exports tend to start with a byte order mark
+				name = strings.TrimPrefix(name, "\ufeff")
+			}
+			name = strings.TrimSpace(name)
+			if renamed, ok := c.Rename[name]; ok {
+				name = renamed
+			}
+			c.header[i] = name
+		}
+	}
+	record, err := c.reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	data := make(map[string]interface{}, len(c.header))
+	for i, val := range record {
+		if c.header[i] == "" {
+			continue
+		}
+		if c.InferTypes {
+			data[c.header[i]] = inferCSVValue(val)
+		} else {
+			data[c.header[i]] = val
+		}
+	}
+	return NewDynamicMap(data, c.MessageFields...), nil
+}
+
+// inferCSVValue converts a cell to float64 only if no information would be lost
+// Values such as 007, 1.50 or 1e3 are kept as strings
+func inferCSVValue(val string) interface{} {
+	if val == "" {
+		return val
+	}
+	f, err := strconv.ParseFloat(val, 64)
+	if err != nil || strconv.FormatFloat(f, 'f', -1, 64) != val {
+		return val
+	}
+	return f
+}
@@ -0,0 +1,71 @@
+package sigma
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestCSVReader(t *testing.T) {
+	data := "\ufeffHost Name,Process,PID,Code,\n" +
+		`ws01,"C:\Windows\System32\cmd.exe",4242,007,x` + "\n" +
+		`ws02,"powershell.exe -c ""whoami""",-12.5,1e3,` + "\n"
+	r := NewCSVReader(strings.NewReader(data))
+	r.Rename = map[string]string{"Host Name": "Computer", "Process": "Image"}
+	r.MessageFields = []string{"Image"}
+
+	e, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := r.Header(); len(h) != 5 || h[0] != "Computer" || h[1] != "Image" {
+		t.Fatalf("wrong header %+v", h)
+	}
+	for k, v := range map[string]interface{}{
+		"Computer": "ws01",
+		"Image":    `C:\Windows\System32\cmd.exe`,
+		"PID":      float64(4242),
+		"Code":     "007",
+	} {
+		if val, ok := e.GetField(k); !ok || val != v {
+			t.Fatalf("%s: expected %#v, got %#v", k, v, val)
+		}
+	}
+	if _, ok := e.GetField(""); ok {
+		t.Fatal("column without header should be skipped")
+	}
+	f, err := NewFields(map[string]interface{}{"PID": 4242}, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.Match(e) {
+		t.Fatal("numeric pattern should match inferred column")
+	}
+
+	e, err = r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg := e.GetMessage(); len(msg) != 1 || msg[0] != `powershell.exe -c "whoami"` {
+		t.Fatalf("wrong message %+v", msg)
+	}
+	if val, _ := e.GetField("PID"); val != -12.5 {
+		t.Fatalf("expected -12.5, got %#v", val)
+	}
+	if val, _ := e.GetField("Code"); val != "1e3" {
+		t.Fatalf("expected 1e3 as string, got %#v", val)
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+
+	tsv := NewTSVReader(strings.NewReader("user\tcmd\nroot\techo \"hi\n"))
+	tsv.InferTypes = false
+	e, err = tsv.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val, _ := e.GetField("cmd"); val != `echo "hi` {
+		t.Fatalf("wrong TSV value %#v", val)
+	}
+}
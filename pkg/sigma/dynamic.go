@@ -0,0 +1,138 @@
+package sigma
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DefaultMessageFields are used by DynamicMap for keyword rules if no message fields are configured
+var DefaultMessageFields = []string{"message"}
+
+// DynamicMap implements EventChecker for decoded JSON or any other nested map[string]interface{}
+// Nested fields are accessed with dotted paths such as process.parent.name
+// Keys that contain dots are matched verbatim before the path is split
+// Path segments that are numbers index into lists, other segments are resolved against every list element
+type DynamicMap struct {
+	Data map[string]interface{}
+
+	// MessageFields are field paths that are returned by GetMessage for keyword rules
+	MessageFields []string
+}
+
+// NewDynamicMap wraps a decoded event
+func NewDynamicMap(data map[string]interface{}, messageFields ...string) *DynamicMap {
+	return &DynamicMap{
+		Data:          data,
+		MessageFields: messageFields,
+	}
+}
+
+// NewDynamicMapFromJSON decodes a JSON object
+func NewDynamicMapFromJSON(data []byte, messageFields ...string) (*DynamicMap, error) {
+	var obj map[string]interface{}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, err
+	}
+	return NewDynamicMap(obj, messageFields...), nil
+}
+
+// GetMessage implements MessageGetter
+func (d DynamicMap) GetMessage() []string {
+	fields := d.MessageFields
+	if len(fields) == 0 {
+		fields = DefaultMessageFields
+	}
+	out := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if val, ok := d.GetField(field); ok {
+			out = appendMessage(out, val)
+		}
+	}
+	return out
+}
+
+// GetField implements SelectionGetter
+func (d DynamicMap) GetField(key string) (interface{}, bool) {
+	return lookupPath(d.Data, key)
+}
+
+func appendMessage(out []string, val interface{}) []string {
+	switch v := val.(type) {
+	case nil:
+		return out
+	case string:
+		return append(out, v)
+	case []interface{}:
+		for _, item := range v {
+			out = appendMessage(out, item)
+		}
+		return out
+	default:
+		return append(out, fmt.Sprintf("%v", v))
+	}
+}
+
+// lookupPath resolves a dotted path in nested maps and lists
+func lookupPath(v interface{}, path string) (interface{}, bool) {
+	switch data := v.(type) {
+	case map[string]interface{}:
+		if val, ok := data[path]; ok {
+			return val, true
+		}
+		for i := 0; i < len(path); i++ {
+			if path[i] != '.' {
+				continue
+			}
+			if val, ok := data[path[:i]]; ok {
+				if res, ok := lookupPath(val, path[i+1:]); ok {
+					return res, true
+				}
+			}
+		}
+	case map[interface{}]interface{}:
+		if val, ok := data[path]; ok {
+			return val, true
+		}
+		for i := 0; i < len(path); i++ {
+			if path[i] != '.' {
+				continue
+			}
+			if val, ok := data[path[:i]]; ok {
+				if res, ok := lookupPath(val, path[i+1:]); ok {
+					return res, true
+				}
+			}
+		}
+	case []interface{}:
+		head, tail := path, ""
+		if i := strings.IndexByte(path, '.'); i >= 0 {
+			head, tail = path[:i], path[i+1:]
+		}
+		if idx, err := strconv.Atoi(head); err == nil {
+			if idx < 0 || idx >= len(data) {
+				return nil, false
+			}
+			if tail == "" {
+				return data[idx], true
+			}
+			return lookupPath(data[idx], tail)
+		}
+		// any element semantics, results from every element are collected into a single list
+		out := make([]interface{}, 0)
+		for _, item := range data {
+			if res, ok := lookupPath(item, path); ok {
+				if list, ok := res.([]interface{}); ok {
+					out = append(out, list...)
+				} else {
+					out = append(out, res)
+				}
+			}
+		}
+		if len(out) > 0 {
+			return out, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,77 @@
+package sigma
+
+import (
+	"reflect"
+	"testing"
+)
+
+var dynamicExample = `{
+	"message": "user root logged in",
+	"event.code": "4688",
+	"process": {
+		"name": "cmd.exe",
+		"pid": 1234,
+		"parent": {
+			"name": "explorer.exe"
+		},
+		"args": ["cmd.exe", "/c", "whoami"]
+	},
+	"dns": {
+		"answers": [
+			{"name": "example.com", "data": "10.0.0.1"},
+			{"name": "example.com", "data": "10.0.0.2"}
+		]
+	},
+	"tags": ["a", "b"]
+}`
+
+func TestDynamicMap(t *testing.T) {
+	obj, err := NewDynamicMapFromJSON([]byte(dynamicExample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		key   string
+		value interface{}
+		found bool
+	}{
+		{key: "event.code", value: "4688", found: true},
+		{key: "process.name", value: "cmd.exe", found: true},
+		{key: "process.pid", value: float64(1234), found: true},
+		{key: "process.parent.name", value: "explorer.exe", found: true},
+		{key: "process.args.2", value: "whoami", found: true},
+		{key: "process.args.3"},
+		{key: "dns.answers.data", value: []interface{}{"10.0.0.1", "10.0.0.2"}, found: true},
+		{key: "dns.answers.1.data", value: "10.0.0.2", found: true},
+		{key: "tags", value: []interface{}{"a", "b"}, found: true},
+		{key: "process.parent.pid"},
+		{key: "missing"},
+	} {
+		val, ok := obj.GetField(c.key)
+		if ok != c.found || !reflect.DeepEqual(val, c.value) {
+			t.Fatalf("%s: expected %v %v, got %v %v", c.key, c.value, c.found, val, ok)
+		}
+	}
+	if msg := obj.GetMessage(); len(msg) != 1 || msg[0] != "user root logged in" {
+		t.Fatalf("wrong default message: %+v", msg)
+	}
+	obj.MessageFields = []string{"process.name", "process.args"}
+	if msg := obj.GetMessage(); !reflect.DeepEqual(msg, []string{"cmd.exe", "cmd.exe", "/c", "whoami"}) {
+		t.Fatalf("wrong message: %+v", msg)
+	}
+
+	tree, err := ParseDetection(Detection{
+		"condition": "selection and keywords",
+		"selection": map[string]interface{}{
+			"process.parent.name": "explorer",
+			"process.pid":         1234,
+		},
+		"keywords": []interface{}{"whoami"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tree.Match(obj) {
+		t.Fatal("rule did not match dynamic map")
+	}
+}
@@ -0,0 +1,166 @@
+package sigma
+
+import (
+	"encoding/xml"
+	"strconv"
+	"strings"
+)
+
+// EvtxEvent implements EventChecker for rendered Windows Event XML
+// System attributes are exposed as EventID, Channel, Computer, Provider_Name, etc
+// Named EventData and UserData elements are exposed by their names, like sigma rules expect
+// Unnamed EventData elements are collected into a Data list
+type EvtxEvent struct {
+	Fields map[string]interface{}
+
+	// Message is the rendered message from RenderingInfo
+	Message string
+
+	// EventData values in document order
+	data []string
+}
+
+type evtxXML struct {
+	System struct {
+		Provider struct {
+			Name            string `xml:"Name,attr"`
+			Guid            string `xml:"Guid,attr"`
+			EventSourceName string `xml:"EventSourceName,attr"`
+		} `xml:"Provider"`
+		EventID struct {
+			Value      string `xml:",chardata"`
+			Qualifiers string `xml:"Qualifiers,attr"`
+		} `xml:"EventID"`
+		Version     string `xml:"Version"`
+		Level       string `xml:"Level"`
+		Task        string `xml:"Task"`
+		Opcode      string `xml:"Opcode"`
+		Keywords    string `xml:"Keywords"`
+		TimeCreated struct {
+			SystemTime string `xml:"SystemTime,attr"`
+		} `xml:"TimeCreated"`
+		EventRecordID string `xml:"EventRecordID"`
+		Correlation   struct {
+			ActivityID        string `xml:"ActivityID,attr"`
+			RelatedActivityID string `xml:"RelatedActivityID,attr"`
+		} `xml:"Correlation"`
+		Execution struct {
+			ProcessID string `xml:"ProcessID,attr"`
+			ThreadID  string `xml:"ThreadID,attr"`
+		} `xml:"Execution"`
+		Channel  string `xml:"Channel"`
+		Computer string `xml:"Computer"`
+		Security struct {
+			UserID string `xml:"UserID,attr"`
+		} `xml:"Security"`
+	} `xml:"System"`
+	EventData struct {
+		Data []struct {
+			Name  string `xml:"Name,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"Data"`
+	} `xml:"EventData"`
+	UserData struct {
+		Nodes []xmlNode `xml:",any"`
+	} `xml:"UserData"`
+	RenderingInfo struct {
+		Message string `xml:"Message"`
+	} `xml:"RenderingInfo"`
+}
+
+type xmlNode struct {
+	XMLName xml.Name
+	Content string    `xml:",chardata"`
+	Nodes   []xmlNode `xml:",any"`
+}
+
+// NewEvtxEvent parses a single rendered <Event> element
+func NewEvtxEvent(data []byte) (*EvtxEvent, error) {
+	var raw evtxXML
+	if err := xml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	e := &EvtxEvent{
+		Fields:  make(map[string]interface{}),
+		Message: strings.TrimSpace(raw.RenderingInfo.Message),
+	}
+	sys := raw.System
+	for k, v := range map[string]string{
+		"Provider_Name":          sys.Provider.Name,
+		"Provider_Guid":          sys.Provider.Guid,
+		"EventSourceName":        sys.Provider.EventSourceName,
+		"Qualifiers":             sys.EventID.Qualifiers,
+		"Keywords":               sys.Keywords,
+		"TimeCreated_SystemTime": sys.TimeCreated.SystemTime,
+		"ActivityID":             sys.Correlation.ActivityID,
+		"RelatedActivityID":      sys.Correlation.RelatedActivityID,
+		"Channel":                sys.Channel,
+		"Computer":               sys.Computer,
+		"Security_UserID":        sys.Security.UserID,
+		"Execution_ProcessID":    sys.Execution.ProcessID,
+		"Execution_ThreadID":     sys.Execution.ThreadID,
+	} {
+		if v = strings.TrimSpace(v); v != "" {
+			e.Fields[k] = v
+		}
+	}
+	// numeric system fields, so they match numeric patterns such as EventID: 4688
+	for k, v := range map[string]string{
+		"EventID":       sys.EventID.Value,
+		"Version":       sys.Version,
+		"Level":         sys.Level,
+		"Task":          sys.Task,
+		"Opcode":        sys.Opcode,
+		"EventRecordID": sys.EventRecordID,
+	} {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		} else if n, err := strconv.Atoi(v); err == nil {
+			e.Fields[k] = n
+		} else {
+			e.Fields[k] = v
+		}
+	}
+	unnamed := make([]interface{}, 0)
+	for _, d := range raw.EventData.Data {
+		e.data = append(e.data, d.Value)
+		if d.Name == "" {
+			unnamed = append(unnamed, d.Value)
+			continue
+		}
+		e.Fields[d.Name] = d.Value
+	}
+	if len(unnamed) > 0 {
+		e.Fields["Data"] = unnamed
+	}
+	for _, n := range raw.UserData.Nodes {
+		n.flatten(e.Fields)
+	}
+	return e, nil
+}
+
+// flatten collects leaf elements by their local name
+func (n xmlNode) flatten(out map[string]interface{}) {
+	if len(n.Nodes) == 0 {
+		out[n.XMLName.Local] = strings.TrimSpace(n.Content)
+		return
+	}
+	for _, child := range n.Nodes {
+		child.flatten(out)
+	}
+}
+
+// GetMessage implements MessageGetter
+// Rendered message is returned if present, EventData values otherwise
+func (e EvtxEvent) GetMessage() []string {
+	if e.Message != "" {
+		return []string{e.Message}
+	}
+	return e.data
+}
+
+// GetField implements SelectionGetter
+func (e EvtxEvent) GetField(key string) (interface{}, bool) {
+	val, ok := e.Fields[key]
+	return val, ok
+}
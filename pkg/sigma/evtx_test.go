@@ -0,0 +1,111 @@
+package sigma
+
+import (
+	"testing"
+)
+
+var evtxSysmonExample = `<Event xmlns="http://schemas.microsoft.com/win/2004/08/events/event">
+  <System>
+    <Provider Name="Microsoft-Windows-Sysmon" Guid="{5770385f-c22a-43e0-bf4c-06f5698ffbd9}" />
+    <EventID>1</EventID>
+    <Version>5</Version>
+    <Level>4</Level>
+    <Task>1</Task>
+    <Opcode>0</Opcode>
+    <Keywords>0x8000000000000000</Keywords>
+    <TimeCreated SystemTime="2020-01-16T10:00:00.000000000Z" />
+    <EventRecordID>4242</EventRecordID>
+    <Correlation />
+    <Execution ProcessID="2012" ThreadID="3044" />
+    <Channel>Microsoft-Windows-Sysmon/Operational</Channel>
+    <Computer>WS01.corp.local</Computer>
+    <Security UserID="S-1-5-18" />
+  </System>
+  <EventData>
+    <Data Name="RuleName">-</Data>
+    <Data Name="ProcessId">6688</Data>
+    <Data Name="Image">C:\Windows\System32\cmd.exe</Data>
+    <Data Name="CommandLine">cmd.exe /c whoami &amp;&amp; hostname</Data>
+    <Data Name="ParentImage">C:\Windows\explorer.exe</Data>
+  </EventData>
+  <RenderingInfo Culture="en-US">
+    <Message>Process Create: RuleName: - Image: C:\Windows\System32\cmd.exe</Message>
+    <Level>Information</Level>
+  </RenderingInfo>
+</Event>`
+
+var evtxUserDataExample = `<Event xmlns="http://schemas.microsoft.com/win/2004/08/events/event">
+  <System>
+    <Provider Name="Microsoft-Windows-Eventlog" Guid="{fc65ddd8-d6ef-4962-83d5-6e5cfe9ce148}" />
+    <EventID Qualifiers="0">1102</EventID>
+    <Channel>Security</Channel>
+    <Computer>DC01</Computer>
+  </System>
+  <UserData>
+    <LogFileCleared xmlns="http://manifests.microsoft.com/win/2004/08/windows/eventlog">
+      <SubjectUserSid>S-1-5-21-1</SubjectUserSid>
+      <SubjectUserName>alice</SubjectUserName>
+      <SubjectDomainName>CORP</SubjectDomainName>
+    </LogFileCleared>
+  </UserData>
+</Event>`
+
+func TestEvtxEvent(t *testing.T) {
+	e, err := NewEvtxEvent([]byte(evtxSysmonExample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range map[string]interface{}{
+		"EventID":                1,
+		"EventRecordID":          4242,
+		"Channel":                "Microsoft-Windows-Sysmon/Operational",
+		"Computer":               "WS01.corp.local",
+		"Provider_Name":          "Microsoft-Windows-Sysmon",
+		"Execution_ProcessID":    "2012",
+		"Security_UserID":        "S-1-5-18",
+		"TimeCreated_SystemTime": "2020-01-16T10:00:00.000000000Z",
+		"ProcessId":              "6688",
+		"CommandLine":            "cmd.exe /c whoami && hostname",
+	} {
+		if val, ok := e.GetField(k); !ok || val != v {
+			t.Fatalf("%s: expected %v, got %v", k, v, val)
+		}
+	}
+	if msg := e.GetMessage(); len(msg) != 1 || msg[0] != "Process Create: RuleName: - Image: C:\\Windows\\System32\\cmd.exe" {
+		t.Fatalf("wrong message %+v", msg)
+	}
+	tree, err := ParseDetection(Detection{
+		"condition": "selection and keywords",
+		"selection": map[string]interface{}{
+			"EventID":        1,
+			"Image|endswith": `\cmd.exe`,
+			"ParentImage":    `*\explorer.exe`,
+			"CommandLine":    "whoami",
+		},
+		"keywords": []interface{}{"Process Create"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tree.Match(e) {
+		t.Fatal("rule did not match evtx event")
+	}
+
+	e, err = NewEvtxEvent([]byte(evtxUserDataExample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range map[string]interface{}{
+		"EventID":         1102,
+		"Qualifiers":      "0",
+		"Channel":         "Security",
+		"SubjectUserName": "alice",
+	} {
+		if val, ok := e.GetField(k); !ok || val != v {
+			t.Fatalf("%s: expected %v, got %v", k, v, val)
+		}
+	}
+	if _, err := NewEvtxEvent([]byte("<Event><System>")); err == nil {
+		t.Fatal("malformed XML should return error")
+	}
+}
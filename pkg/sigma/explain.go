@@ -0,0 +1,247 @@
+package sigma
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Explanation is a full evaluation trace of a single rule against a single event
+type Explanation struct {
+	ID     string `json:"id"`
+	Title  string `json:"title"`
+	Path   string `json:"path,omitempty"`
+	Result bool   `json:"result"`
+	Trace  *Trace `json:"trace"`
+}
+
+// Trace describes evaluation of a single tree node
+type Trace struct {
+	// Node is one of and, or, not, selection, selection list or keywords
+	Node   string `json:"node"`
+	Ident  string `json:"ident,omitempty"`
+	Result bool   `json:"result"`
+	// Skipped is set for nodes that Match would not evaluate as result was already decided by a preceding sibling
+	// Skipped nodes are evaluated regardless to show their result
+	Skipped bool `json:"skipped,omitempty"`
+
+	// Patterns are keyword patterns
+	Patterns []string       `json:"patterns,omitempty"`
+	Messages []MessageTrace `json:"messages,omitempty"`
+	Fields   []FieldTrace   `json:"fields,omitempty"`
+	Children []*Trace       `json:"children,omitempty"`
+}
+
+// FieldTrace describes a single field of a selection
+type FieldTrace struct {
+	Field    string      `json:"field"`
+	Patterns []string    `json:"patterns"`
+	Present  bool        `json:"present"`
+	Value    interface{} `json:"value,omitempty"`
+	Result   bool        `json:"result"`
+}
+
+// MessageTrace describes keyword patterns applied to a single event message
+type MessageTrace struct {
+	Message string `json:"message"`
+	Result  bool   `json:"result"`
+}
+
+// Explain evaluates rule against event and records result of every node, pattern and short circuit
+// Trace follows detection as written in the rule, not the cost ordered tree used by Check, so skipped
+// branches are those the condition itself would short circuit
+// Rule statistics are not updated
+func Explain(rule *Rule, obj EventChecker) *Explanation {
+	t := rule.Source().Explain(obj)
+	return &Explanation{
+		ID:     rule.ID,
+		Title:  rule.Title,
+		Path:   rule.Path,
+		Result: t.Result,
+		Trace:  t,
+	}
+}
+
+// Explain returns evaluation trace of the tree
+func (t Tree) Explain(obj EventChecker) *Trace {
+	return explainBranch(t.Root, obj, false)
+}
+
+func explainBranch(b Branch, obj EventChecker, skipped bool) *Trace {
+	switch n := b.(type) {
+	case NodeSimpleAnd:
+		return explainChildren("and", []Branch(n), obj, skipped, false)
+	case NodeSimpleOr:
+		return explainChildren("or", []Branch(n), obj, skipped, true)
+	case NodeAnd:
+		return explainChildren("and", []Branch{n.L, n.R}, obj, skipped, false)
+	case NodeOr:
+		return explainChildren("or", []Branch{n.L, n.R}, obj, skipped, true)
+	case NodeNot:
+		child := explainBranch(n.Branch, obj, skipped)
+		return &Trace{Node: "not", Result: !child.Result, Skipped: skipped, Children: []*Trace{child}}
+	case FieldsList:
+		branches := make([]Branch, len(n))
+		for i, f := range n {
+			branches[i] = f
+		}
+		t := explainChildren("selection list", branches, obj, skipped, true)
+		t.Ident = n.Ident()
+		return t
+	case *Fields:
+		return n.explain(obj, skipped)
+	case *Keyword:
+		return n.explain(obj, skipped)
+	default:
+		return &Trace{Node: fmt.Sprintf("%T", b), Result: b.Match(obj), Skipped: skipped}
+	}
+}
+
+// explainChildren evaluates and or or node, decisive is the child result that short circuits the node
+func explainChildren(node string, children []Branch, obj EventChecker, skipped, decisive bool) *Trace {
+	t := &Trace{Node: node, Result: !decisive, Skipped: skipped, Children: make([]*Trace, 0, len(children))}
+	decided := false
+	for _, b := range children {
+		child := explainBranch(b, obj, skipped || decided)
+		t.Children = append(t.Children, child)
+		if !decided && child.Result == decisive {
+			t.Result = decisive
+			decided = true
+		}
+	}
+	return t
+}
+
+func (f *Fields) explain(obj EventChecker, skipped bool) *Trace {
+	t := &Trace{Node: "selection", Ident: f.ident, Result: true, Skipped: skipped}
+	for field, p := range f.sPatterns {
+		val, ok := obj.GetField(field)
+		t.Fields = append(t.Fields, FieldTrace{
+			Field: field, Patterns: p.patterns(), Present: ok, Value: val,
+			Result: ok && matchValue(p, f.toLower, val),
+		})
+	}
+	for field, p := range f.nPatterns {
+		val, ok := obj.GetField(field)
+		t.Fields = append(t.Fields, FieldTrace{
+			Field: field, Patterns: p.patterns(), Present: ok, Value: val,
+			Result: ok && p.matchNumber(val, f.tryStingNumbers),
+		})
+	}
+	for field, p := range f.bPatterns {
+		val, ok := obj.GetField(field)
+		t.Fields = append(t.Fields, FieldTrace{
+			Field: field, Patterns: p.patterns(), Present: ok, Value: val,
+			Result: ok && p.matchBool(val, f.tryStingNumbers),
+		})
+	}
+	for _, field := range f.nulls {
+		val, ok := obj.GetField(field)
+		t.Fields = append(t.Fields, FieldTrace{
+			Field: field, Patterns: []string{"null"}, Present: ok, Value: val,
+			Result: !ok || val == nil,
+		})
+	}
+	sort.Slice(t.Fields, func(i, j int) bool { return t.Fields[i].Field < t.Fields[j].Field })
+	for _, field := range t.Fields {
+		t.Result = t.Result && field.Result
+	}
+	return t
+}
+
+func (k *Keyword) explain(obj EventChecker, skipped bool) *Trace {
+	t := &Trace{Node: "keywords", Ident: k.ident, Skipped: skipped, Patterns: k.patterns()}
+	for _, msg := range obj.GetMessage() {
+		ok := matchKeywords(k.stringPatterns, k.toLower, msg)
+		t.Messages = append(t.Messages, MessageTrace{Message: msg, Result: ok})
+		t.Result = t.Result || ok
+	}
+	return t
+}
+
+// patterns returns string representation of patterns as written in rule
+func (s stringPatterns) patterns() []string {
+	out := make([]string, 0, len(s.literals)+len(s.re)+len(s.globs))
+	out = append(out, s.literals...)
+	for _, re := range s.re {
+		out = append(out, "/"+re.String()+"/")
+	}
+	for _, g := range s.globs {
+		out = append(out, g.pattern)
+	}
+	return out
+}
+
+func (p numPatterns) patterns() []string {
+	out := make([]string, len(p))
+	for i, n := range p {
+		out[i] = strconv.FormatFloat(n, 'f', -1, 64)
+	}
+	return out
+}
+
+func (p boolPatterns) patterns() []string {
+	out := make([]string, len(p))
+	for i, b := range p {
+		out[i] = strconv.FormatBool(b)
+	}
+	return out
+}
+
+// String renders explanation as text tree
+func (e Explanation) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s (%s): %s\n", e.Title, e.ID, resultString(e.Result))
+	if e.Trace != nil {
+		e.Trace.render(&b, "", true)
+	}
+	return b.String()
+}
+
+// String renders trace as text tree
+func (t Trace) String() string {
+	var b strings.Builder
+	t.render(&b, "", true)
+	return b.String()
+}
+
+func (t Trace) render(b *strings.Builder, prefix string, last bool) {
+	branch, indent := "├── ", "│   "
+	if last {
+		branch, indent = "└── ", "    "
+	}
+	b.WriteString(prefix + branch + t.Node)
+	if t.Ident != "" {
+		b.WriteString(" " + t.Ident)
+	}
+	b.WriteString(": " + resultString(t.Result))
+	if t.Skipped {
+		b.WriteString(" (skipped)")
+	}
+	b.WriteString("\n")
+	prefix += indent
+	if len(t.Patterns) > 0 {
+		fmt.Fprintf(b, "%s    patterns %q\n", prefix, t.Patterns)
+	}
+	for _, m := range t.Messages {
+		fmt.Fprintf(b, "%s    %q: %s\n", prefix, m.Message, resultString(m.Result))
+	}
+	for _, f := range t.Fields {
+		if f.Present {
+			fmt.Fprintf(b, "%s    %s %q = %#v: %s\n", prefix, f.Field, f.Patterns, f.Value, resultString(f.Result))
+		} else {
+			fmt.Fprintf(b, "%s    %s %q missing: %s\n", prefix, f.Field, f.Patterns, resultString(f.Result))
+		}
+	}
+	for i, c := range t.Children {
+		c.render(b, prefix, i == len(t.Children)-1)
+	}
+}
+
+func resultString(ok bool) string {
+	if ok {
+		return "match"
+	}
+	return "no match"
+}
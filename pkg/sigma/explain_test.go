@@ -0,0 +1,89 @@
+package sigma
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const explainRule = `title: explain
+id: 5f1abf38-3f4d-4bd6-b8e2-6d4b1d8e0a01
+logsource:
+    product: windows
+detection:
+    selection:
+        Image|endswith: '\cmd.exe'
+        ParentPid: 4
+    filter:
+        User: SYSTEM
+    keywords:
+        - recon
+    condition: selection and not filter or keywords
+`
+
+func TestExplain(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sigma-explain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "explain.yml")
+	if err := ioutil.WriteFile(path, []byte(explainRule), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rule, err := LoadRule(path, RegexLimits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := rule.Source().String(); s != "(selection and not filter) or keywords" || s == rule.Tree().String() {
+		t.Fatalf("source should be detection as written, got %s, optimized %s", s, rule.Tree())
+	}
+
+	event := messageObject{"Image": `C:\cmd.exe`, "ParentPid": "4", "User": "SYSTEM", "Message": "recon"}
+	e := Explain(rule, event)
+	if !e.Result || e.Title != "explain" || e.Path != path {
+		t.Fatalf("wrong explanation %+v", e)
+	}
+	root := e.Trace
+	if root.Node != "or" || !root.Result || len(root.Children) != 2 {
+		t.Fatalf("wrong root %+v", root)
+	}
+	and, kw := root.Children[0], root.Children[1]
+	if and.Node != "and" || and.Result || kw.Node != "keywords" || !kw.Result || kw.Skipped {
+		t.Fatalf("wrong or branches %+v %+v", and, kw)
+	}
+	sel, not := and.Children[0], and.Children[1]
+	if sel.Ident != "selection" || !sel.Result || len(sel.Fields) != 2 || sel.Fields[0].Field != "Image" {
+		t.Fatalf("wrong selection %+v", sel)
+	}
+	if not.Node != "not" || not.Result || not.Skipped || not.Children[0].Ident != "filter" {
+		t.Fatalf("wrong negation %+v", not)
+	}
+
+	e = Explain(rule, messageObject{"Image": `C:\bash.exe`})
+	if e.Result {
+		t.Fatal("rule should not match")
+	}
+	not = e.Trace.Children[0].Children[1]
+	if !not.Skipped || !not.Result || not.Children[0].Fields[0].Present {
+		t.Fatalf("filter should be evaluated but marked as skipped, got %+v", not)
+	}
+	text := e.String()
+	for _, s := range []string{
+		"explain (5f1abf38-3f4d-4bd6-b8e2-6d4b1d8e0a01): no match",
+		`Image ["\\cmd.exe"] = "C:\\bash.exe": no match`,
+		`ParentPid ["4"] missing: no match`,
+		"not: match (skipped)",
+	} {
+		if !strings.Contains(text, s) {
+			t.Fatalf("missing %s from\n%s", s, text)
+		}
+	}
+	if _, err := json.Marshal(e); err != nil {
+		t.Fatal(err)
+	}
+}
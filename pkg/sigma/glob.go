@@ -0,0 +1,142 @@
+package sigma
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// globMatcher is a glob pattern compiled into segments
+// Semantics are equal to github.com/ryanuber/go-glob, where * matches any sequence and is the only wildcard
+// Common shapes such as foo*, *foo and *foo* are matched with a single prefix, suffix or substring check
+type globMatcher struct {
+	pattern string
+	kind    globKind
+
+	// prefix must start the subject unless pattern starts with *
+	prefix string
+	// middle segments must occur in order after prefix
+	middle []string
+	// suffix must end the subject unless pattern ends with *
+	suffix string
+}
+
+type globKind int
+
+const (
+	globExact globKind = iota
+	globAny
+	globPrefix
+	globSuffix
+	globContains
+	globSegments
+)
+
+func newGlobMatcher(pattern string) globMatcher {
+	g := globMatcher{pattern: pattern}
+	if !strings.Contains(pattern, "*") {
+		g.kind, g.prefix = globExact, pattern
+		return g
+	}
+	parts := strings.Split(pattern, "*")
+	g.prefix, g.suffix = parts[0], parts[len(parts)-1]
+	for _, p := range parts[1 : len(parts)-1] {
+		// consecutive wildcards do not add a constraint
+		if p != "" {
+			g.middle = append(g.middle, p)
+		}
+	}
+	switch {
+	case g.prefix == "" && g.suffix == "" && len(g.middle) == 0:
+		g.kind = globAny
+	case g.suffix == "" && len(g.middle) == 0:
+		g.kind = globPrefix
+	case g.prefix == "" && len(g.middle) == 0:
+		g.kind = globSuffix
+	case g.prefix == "" && g.suffix == "" && len(g.middle) == 1:
+		g.kind = globContains
+	default:
+		g.kind = globSegments
+	}
+	return g
+}
+
+// match reports whether subject matches the pattern
+// If fold is set, subject is lowercased before matching like strings.ToLower, but ASCII subjects are not copied
+func (g globMatcher) match(s string, fold bool) bool {
+	if fold && !isASCII(s) {
+		s, fold = strings.ToLower(s), false
+	}
+	switch g.kind {
+	case globExact:
+		return len(s) == len(g.prefix) && hasPrefix(s, g.prefix, fold)
+	case globAny:
+		return true
+	case globPrefix:
+		return hasPrefix(s, g.prefix, fold)
+	case globSuffix:
+		return hasSuffix(s, g.suffix, fold)
+	case globContains:
+		return index(s, g.middle[0], fold) >= 0
+	}
+	if !hasPrefix(s, g.prefix, fold) {
+		return false
+	}
+	s = s[len(g.prefix):]
+	for _, m := range g.middle {
+		i := index(s, m, fold)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(m):]
+	}
+	return hasSuffix(s, g.suffix, fold)
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// equalLower compares lowercased ASCII subject to pattern of same length
+func equalLower(s, pattern string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		if c != pattern[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func hasPrefix(s, prefix string, fold bool) bool {
+	if !fold {
+		return strings.HasPrefix(s, prefix)
+	}
+	return len(s) >= len(prefix) && equalLower(s[:len(prefix)], prefix)
+}
+
+func hasSuffix(s, suffix string, fold bool) bool {
+	if !fold {
+		return strings.HasSuffix(s, suffix)
+	}
+	return len(s) >= len(suffix) && equalLower(s[len(s)-len(suffix):], suffix)
+}
+
+func index(s, substr string, fold bool) int {
+	if !fold {
+		return strings.Index(s, substr)
+	}
+	for i := 0; i+len(substr) <= len(s); i++ {
+		if equalLower(s[i:i+len(substr)], substr) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,91 @@
+package sigma
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/ryanuber/go-glob"
+)
+
+func TestGlobMatcher(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 5000; i++ {
+		pattern := randomString(r, "ab*", 6)
+		g := newGlobMatcher(pattern)
+		for j := 0; j < 20; j++ {
+			s := randomString(r, "abAB", 8)
+			if g.match(s, false) != glob.Glob(pattern, s) {
+				t.Fatalf("%q against %q: expected %t", pattern, s, glob.Glob(pattern, s))
+			}
+			if g.match(s, true) != glob.Glob(pattern, strings.ToLower(s)) {
+				t.Fatalf("%q against %q case insensitive: expected %t", pattern, s, !g.match(s, true))
+			}
+		}
+	}
+	for _, tc := range []struct {
+		pattern, s string
+		fold       bool
+		match      bool
+	}{
+		{`*\cmd.exe`, `C:\Windows\System32\CMD.EXE`, true, true},
+		{`*\cmd.exe`, `C:\Windows\System32\CMD.EXE`, false, false},
+		{`c:\*\cmd.exe`, `C:\Windows\cmd.exe`, true, true},
+		{`*ärger*`, `ÄRGER`, true, true},
+		{`*ärger*`, `ÄRGER`, false, false},
+		{`a*b*c`, `abc`, false, true},
+		{`a*b*c`, `acb`, false, false},
+		{`ab*ba`, `aba`, false, false},
+		{`**`, ``, false, true},
+	} {
+		if newGlobMatcher(tc.pattern).match(tc.s, tc.fold) != tc.match {
+			t.Fatalf("%q against %q, fold %t: expected %t", tc.pattern, tc.s, tc.fold, tc.match)
+		}
+	}
+	g := newGlobMatcher(`*\windows\*\powershell*.exe`)
+	if allocs := testing.AllocsPerRun(100, func() {
+		g.match(`C:\WINDOWS\System32\WindowsPowerShell\v1.0\PowerShell_ISE.exe`, true)
+	}); allocs != 0 {
+		t.Fatalf("case insensitive match of ASCII subject should not allocate, got %f", allocs)
+	}
+}
+
+var globBenchPatterns = []string{`*\powershell.exe`, `c:\windows\*`, `*-encodedcommand*`, `c:\*\system32\*\cmd.exe`}
+
+const globBenchSubject = `c:\windows\system32\windowspowershell\v1.0\powershell.exe -nop -w hidden -encodedcommand aqbfafgaiaaoag4azqb3ac0a`
+
+func BenchmarkGlob(b *testing.B) {
+	b.Run("go-glob", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, p := range globBenchPatterns {
+				glob.Glob(p, globBenchSubject)
+			}
+		}
+	})
+	compiled := make([]globMatcher, len(globBenchPatterns))
+	for i, p := range globBenchPatterns {
+		compiled[i] = newGlobMatcher(p)
+	}
+	b.Run("compiled", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, g := range compiled {
+				g.match(globBenchSubject, false)
+			}
+		}
+	})
+	upper := strings.ToUpper(globBenchSubject)
+	b.Run("go-glob-lowercase", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, p := range globBenchPatterns {
+				glob.Glob(p, strings.ToLower(upper))
+			}
+		}
+	})
+	b.Run("compiled-fold", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, g := range compiled {
+				g.match(upper, true)
+			}
+		}
+	})
+}
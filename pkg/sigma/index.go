@@ -0,0 +1,278 @@
+package sigma
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RuleIndex dispatches events only to rules that can possibly match them
+// Index keys are derived from constraints every match of a rule must satisfy
+//   - numeric equality, such as EventID: 1
+//   - path basename of globs such as Image: '*\cmd.exe'
+//
+// Rules without such constraints are evaluated for every event
+// Candidates are evaluated in original order, so results are identical to RuleGroup.Check
+type RuleIndex struct {
+	rules RuleGroup
+
+	// rules that are evaluated for every event
+	always []int
+	// field name to value to rule positions
+	numeric  map[string]map[float64][]int
+	basename map[string]map[string][]int
+}
+
+// constraint is a condition every matching event must satisfy
+// Event field should equal one of nums or have a basename from names
+type constraint struct {
+	field string
+	nums  []float64
+	names []string
+}
+
+func (c constraint) numeric() bool { return c.nums != nil }
+
+func (c constraint) size() int { return len(c.nums) + len(c.names) }
+
+// NewRuleIndex builds index over rules
+// Rules are shared with the group and should not be modified afterwards
+func NewRuleIndex(rules RuleGroup) *RuleIndex {
+	idx := &RuleIndex{
+		rules:    rules,
+		always:   make([]int, 0),
+		numeric:  make(map[string]map[float64][]int),
+		basename: make(map[string]map[string][]int),
+	}
+	for i, rule := range rules {
+		c, ok := bestConstraint(requiredConstraints(rule.tree.Root))
+		if !ok {
+			idx.always = append(idx.always, i)
+			continue
+		}
+		if c.numeric() {
+			if idx.numeric[c.field] == nil {
+				idx.numeric[c.field] = make(map[float64][]int)
+			}
+			for _, n := range c.nums {
+				idx.numeric[c.field][n] = appendPosition(idx.numeric[c.field][n], i)
+			}
+			continue
+		}
+		if idx.basename[c.field] == nil {
+			idx.basename[c.field] = make(map[string][]int)
+		}
+		for _, name := range c.names {
+			idx.basename[c.field][name] = appendPosition(idx.basename[c.field][name], i)
+		}
+	}
+	return idx
+}
+
+// appendPosition avoids duplicates when a constraint lists the same value twice
+func appendPosition(positions []int, i int) []int {
+	if l := len(positions); l > 0 && positions[l-1] == i {
+		return positions
+	}
+	return append(positions, i)
+}
+
+// Len returns number of indexed rules
+func (idx RuleIndex) Len() int { return len(idx.rules) - len(idx.always) }
+
+// Check evaluates candidate rules, see RuleGroup.Check
+func (idx RuleIndex) Check(obj EventChecker, firstmatch bool) (Results, bool) {
+	candidates := idx.candidates(obj)
+	res := make(Results, 0)
+	for _, i := range candidates {
+		if result, ok := idx.rules[i].Check(obj); ok {
+			res = append(res, result)
+			if firstmatch {
+				return res, true
+			}
+		}
+	}
+	if len(res) > 0 {
+		return res, true
+	}
+	return nil, false
+}
+
+func (idx RuleIndex) candidates(obj EventChecker) []int {
+	if len(idx.numeric) == 0 && len(idx.basename) == 0 {
+		return idx.always
+	}
+	out := make([]int, len(idx.always))
+	copy(out, idx.always)
+	for field, values := range idx.numeric {
+		if val, ok := obj.GetField(field); ok {
+			eachScalar(val, func(v interface{}) {
+				n, ok := toFloat(v)
+				if !ok {
+					str, isStr := v.(string)
+					if !isStr {
+						return
+					}
+					var err error
+					if n, err = strconv.ParseFloat(strings.TrimSpace(str), 64); err != nil {
+						return
+					}
+				}
+				out = append(out, values[n]...)
+			})
+		}
+	}
+	for field, values := range idx.basename {
+		if val, ok := obj.GetField(field); ok {
+			eachScalar(val, func(v interface{}) {
+				out = append(out, values[pathBase(formatScalar(v))]...)
+			})
+		}
+	}
+	sort.Ints(out)
+	// remove duplicates, a rule may be reached by multiple list elements
+	uniq := out[:0]
+	for i, pos := range out {
+		if i == 0 || pos != out[i-1] {
+			uniq = append(uniq, pos)
+		}
+	}
+	return uniq
+}
+
+// eachScalar calls fn for value or every element of a list value
+func eachScalar(val interface{}, fn func(interface{})) {
+	switch v := val.(type) {
+	case []interface{}:
+		for _, item := range v {
+			eachScalar(item, fn)
+		}
+	case []string:
+		for _, item := range v {
+			fn(item)
+		}
+	default:
+		fn(val)
+	}
+}
+
+// pathBase returns part of path after last slash or backslash
+func pathBase(p string) string {
+	return p[strings.LastIndexAny(p, `\/`)+1:]
+}
+
+// requiredConstraints collects constraints that hold for every event matched by the branch
+func requiredConstraints(b Branch) []constraint {
+	switch n := b.(type) {
+	case Tree:
+		return requiredConstraints(n.Root)
+	case *Tree:
+		return requiredConstraints(n.Root)
+	case *Fields:
+		return n.constraints()
+	case NodeSimpleAnd:
+		out := make([]constraint, 0)
+		for _, child := range n {
+			out = append(out, requiredConstraints(child)...)
+		}
+		return out
+	case NodeAnd:
+		return append(requiredConstraints(n.L), requiredConstraints(n.R)...)
+	case NodeSimpleOr, NodeOr, FieldsList:
+		return anyConstraints(children(b))
+	}
+	return nil
+}
+
+// anyConstraints merges constraints on a field that is constrained by every alternative
+func anyConstraints(alternatives []Branch) []constraint {
+	if len(alternatives) == 0 {
+		return nil
+	}
+	sets := make([][]constraint, len(alternatives))
+	for i, b := range alternatives {
+		if sets[i] = requiredConstraints(b); len(sets[i]) == 0 {
+			return nil
+		}
+	}
+	out := make([]constraint, 0)
+candidates:
+	for _, c := range sets[0] {
+		merged := constraint{field: c.field, nums: c.nums, names: c.names}
+		for _, set := range sets[1:] {
+			found := false
+			for _, other := range set {
+				if other.field == c.field && other.numeric() == c.numeric() {
+					if c.numeric() {
+						merged.nums = append(append([]float64{}, merged.nums...), other.nums...)
+					} else {
+						merged.names = append(append([]string{}, merged.names...), other.names...)
+					}
+					found = true
+					break
+				}
+			}
+			if !found {
+				continue candidates
+			}
+		}
+		out = append(out, merged)
+	}
+	return out
+}
+
+// bestConstraint prefers basename constraints with fewest values
+// Numbers such as EventID tend to be shared by many rules, while process names are more selective
+func bestConstraint(constraints []constraint) (constraint, bool) {
+	var (
+		best  constraint
+		found bool
+	)
+	for _, c := range constraints {
+		if !found || (!c.numeric() && best.numeric()) ||
+			(c.numeric() == best.numeric() && c.size() < best.size()) {
+			best, found = c, true
+		}
+	}
+	return best, found
+}
+
+// constraints of a selection, string patterns are only used if every pattern of the field is a basename glob
+func (f *Fields) constraints() []constraint {
+	out := make([]constraint, 0)
+	for field, p := range f.nPatterns {
+		out = append(out, constraint{field: field, nums: p})
+	}
+	if f.toLower {
+		return out
+	}
+	for _, field := range f.sOrder {
+		if names, ok := f.sPatterns[field].basenames(); ok {
+			out = append(out, constraint{field: field, names: names})
+		}
+	}
+	return out
+}
+
+// basenames returns file names for patterns such as *\cmd.exe that only match paths ending with that name
+func (s stringPatterns) basenames() ([]string, bool) {
+	if len(s.literals) > 0 || len(s.re) > 0 || len(s.globs) == 0 {
+		return nil, false
+	}
+	names := make([]string, 0, len(s.globs))
+	for _, glob := range s.globs {
+		g := glob.pattern
+		if !strings.HasPrefix(g, "*") || len(g) < 3 {
+			return nil, false
+		}
+		if sep := g[1]; sep != '\\' && sep != '/' {
+			return nil, false
+		}
+		name := g[2:]
+		if name == "" || strings.ContainsAny(name, `*\/`) {
+			return nil, false
+		}
+		names = append(names, name)
+	}
+	return names, true
+}
@@ -0,0 +1,176 @@
+package sigma
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+var indexTestImages = []string{"cmd.exe", "powershell.exe", "rundll32.exe", "net.exe", "whoami.exe", "wmic.exe"}
+
+// indexTestRules returns a mix of indexable and non-indexable rules
+func indexTestRules(t testing.TB, n int) RuleGroup {
+	rules := make(RuleGroup, 0, n)
+	for i := 0; i < n; i++ {
+		img := indexTestImages[i%len(indexTestImages)]
+		var det Detection
+		switch i % 5 {
+		case 0:
+			det = Detection{
+				"selection": map[interface{}]interface{}{"EventID": i % 20, "CommandLine": fmt.Sprintf("*arg%d*", i%7)},
+				"condition": "selection",
+			}
+		case 1:
+			det = Detection{
+				"selection": map[interface{}]interface{}{"Image": []interface{}{`*\` + img, "*/" + img}},
+				"filter":    map[interface{}]interface{}{"User": "SYSTEM"},
+				"condition": "selection and not filter",
+			}
+		case 2:
+			det = Detection{
+				"sel1":      map[interface{}]interface{}{"EventID": 1, "Image": `*\` + img},
+				"sel2":      map[interface{}]interface{}{"EventID": []interface{}{4688, 4689}, "NewProcessName": `*\` + img},
+				"condition": "sel1 or sel2",
+			}
+		case 3:
+			det = Detection{
+				"selection": map[interface{}]interface{}{"CommandLine": fmt.Sprintf("/arg%d/", i%7)},
+				"condition": "selection",
+			}
+		case 4:
+			det = Detection{
+				"selection": []interface{}{
+					map[interface{}]interface{}{"Image": `*\` + img},
+					map[interface{}]interface{}{"Image": `*\x` + img},
+				},
+				"keywords":  []interface{}{"evil"},
+				"condition": "selection or keywords",
+			}
+		}
+		tree, err := ParseDetection(det)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rules = append(rules, Rule{
+			tree:    tree.Optimize(),
+			RawRule: RawRule{ID: fmt.Sprintf("rule-%d", i), Title: fmt.Sprintf("rule %d", i)},
+		})
+	}
+	return rules
+}
+
+func randomIndexEvent(r *rand.Rand) messageObject {
+	img := indexTestImages[r.Intn(len(indexTestImages))]
+	e := messageObject{
+		"EventID":     []interface{}{float64(r.Intn(20)), "1", 4688, float64(3)}[r.Intn(4)],
+		"Image":       []interface{}{`C:\Windows\` + img, "/usr/bin/" + img, `C:\x` + img, []interface{}{"a", `c:\` + img}}[r.Intn(4)],
+		"CommandLine": fmt.Sprintf("%s arg%d", img, r.Intn(7)),
+	}
+	if r.Intn(3) == 0 {
+		e["NewProcessName"] = `C:\Windows\` + img
+	}
+	if r.Intn(3) == 0 {
+		e["User"] = "SYSTEM"
+	}
+	if r.Intn(5) == 0 {
+		e["Message"] = "evil things"
+	}
+	return e
+}
+
+func TestRuleIndex(t *testing.T) {
+	rules := indexTestRules(t, 500)
+	idx := NewRuleIndex(rules)
+	// regex and keyword alternatives can not be indexed
+	if expected := 500 - 200; idx.Len() != expected {
+		t.Fatalf("expected %d indexed rules, got %d", expected, idx.Len())
+	}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		e := randomIndexEvent(r)
+		for _, firstmatch := range []bool{false, true} {
+			expected, ok1 := rules.Check(e, firstmatch)
+			got, ok2 := idx.Check(e, firstmatch)
+			if ok1 != ok2 || !reflect.DeepEqual(expected, got) {
+				t.Fatalf("%+v: expected %d results, got %d", e, len(expected), len(got))
+			}
+		}
+	}
+}
+
+func TestRequiredConstraints(t *testing.T) {
+	for _, tc := range []struct {
+		det   Detection
+		c     constraint
+		found bool
+	}{
+		{
+			det: Detection{"sel": map[interface{}]interface{}{"EventID": 1, "Image": `*\cmd.exe`}, "condition": "sel"},
+			c:   constraint{field: "Image", names: []string{"cmd.exe"}}, found: true,
+		},
+		{
+			det: Detection{"sel": map[interface{}]interface{}{"EventID": []interface{}{1, 3}, "User": "root"}, "condition": "sel"},
+			c:   constraint{field: "EventID", nums: []float64{1, 3}}, found: true,
+		},
+		{
+			det: Detection{
+				"a":         map[interface{}]interface{}{"Image": `*\cmd.exe`},
+				"b":         map[interface{}]interface{}{"Image": `*/sh`, "User": "root"},
+				"condition": "a or b",
+			},
+			c: constraint{field: "Image", names: []string{"cmd.exe", "sh"}}, found: true,
+		},
+		{det: Detection{"sel": map[interface{}]interface{}{"Image": `*\cmd*`}, "condition": "sel"}},
+		{det: Detection{"sel": map[interface{}]interface{}{"Image": `C:\cmd.exe`}, "condition": "sel"}},
+		{
+			det: Detection{
+				"sel":       map[interface{}]interface{}{"EventID": 1},
+				"other":     map[interface{}]interface{}{"User": "root"},
+				"condition": "other and not sel",
+			},
+		},
+		{
+			det: Detection{
+				"a":         map[interface{}]interface{}{"EventID": 1},
+				"b":         map[interface{}]interface{}{"Image": `*\cmd.exe`},
+				"condition": "a or b",
+			},
+		},
+	} {
+		tree, err := ParseDetection(tc.det)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, ok := bestConstraint(requiredConstraints(tree.Root))
+		if ok != tc.found || !reflect.DeepEqual(c, tc.c) {
+			t.Fatalf("%+v: expected %+v, got %+v", tc.det, tc.c, c)
+		}
+	}
+}
+
+func BenchmarkRuleIndex(b *testing.B) {
+	// most rules for sysmon and security logs constrain EventID or Image, keep 1 in 10 of others
+	rules := make(RuleGroup, 0)
+	for i, rule := range indexTestRules(b, 8000) {
+		if i%5 < 3 || i%50 < 5 {
+			rules = append(rules, rule)
+		}
+	}
+	events := make([]messageObject, 100)
+	r := rand.New(rand.NewSource(1))
+	for i := range events {
+		events[i] = randomIndexEvent(r)
+	}
+	b.Run("linear", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			rules.Check(events[i%len(events)], false)
+		}
+	})
+	idx := NewRuleIndex(rules)
+	b.Run("indexed", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			idx.Check(events[i%len(events)], false)
+		}
+	})
+}
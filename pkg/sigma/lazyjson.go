@@ -0,0 +1,333 @@
+package sigma
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var errLazyJSON = errors.New("malformed JSON")
+
+// LazyJSON implements EventChecker over raw JSON bytes without decoding the whole event
+// Only values of requested fields are decoded and every lookup is cached, so each field is scanned at most once
+// Field paths follow the same rules as DynamicMap
+// Input is not validated up front, fields inside malformed JSON are reported as missing
+// LazyJSON is not safe for concurrent use, but can be reused for the next event with Reset
+type LazyJSON struct {
+	data  []byte
+	cache map[string]lazyResult
+
+	// MessageFields are field paths that are returned by GetMessage for keyword rules
+	MessageFields []string
+}
+
+type lazyResult struct {
+	val interface{}
+	ok  bool
+}
+
+type lazyPrefix struct {
+	pos, n int
+}
+
+// NewLazyJSON wraps a raw JSON object, data is referenced and should not be modified while event is in use
+func NewLazyJSON(data []byte, messageFields ...string) *LazyJSON {
+	return &LazyJSON{
+		data:          data,
+		cache:         make(map[string]lazyResult),
+		MessageFields: messageFields,
+	}
+}
+
+// Reset replaces event data and clears lookup cache
+func (l *LazyJSON) Reset(data []byte) {
+	l.data = data
+	for k := range l.cache {
+		delete(l.cache, k)
+	}
+}
+
+// GetMessage implements MessageGetter
+func (l *LazyJSON) GetMessage() []string {
+	fields := l.MessageFields
+	if len(fields) == 0 {
+		fields = DefaultMessageFields
+	}
+	out := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if val, ok := l.GetField(field); ok {
+			out = appendMessage(out, val)
+		}
+	}
+	return out
+}
+
+// GetField implements SelectionGetter
+func (l *LazyJSON) GetField(key string) (interface{}, bool) {
+	if res, ok := l.cache[key]; ok {
+		return res.val, res.ok
+	}
+	val, ok := l.resolve(0, key)
+	l.cache[key] = lazyResult{val: val, ok: ok}
+	return val, ok
+}
+
+func (l *LazyJSON) resolve(i int, path string) (interface{}, bool) {
+	i = skipSpace(l.data, i)
+	if i >= len(l.data) {
+		return nil, false
+	}
+	switch l.data[i] {
+	case '{':
+		return l.resolveObject(i, path)
+	case '[':
+		return l.resolveArray(i, path)
+	}
+	return nil, false
+}
+
+func (l *LazyJSON) resolveObject(i int, path string) (interface{}, bool) {
+	var (
+		data     = l.data
+		exact    = -1
+		prefixes []lazyPrefix
+	)
+loop:
+	for i++; ; {
+		i = skipSpace(data, i)
+		if i >= len(data) {
+			return nil, false
+		}
+		switch data[i] {
+		case '}':
+			break loop
+		case ',':
+			i++
+			continue loop
+		case '"':
+		default:
+			return nil, false
+		}
+		end, escaped, err := skipString(data, i)
+		if err != nil {
+			return nil, false
+		}
+		key := data[i+1 : end-1]
+		if escaped {
+			var s string
+			if err := json.Unmarshal(data[i:end], &s); err != nil {
+				return nil, false
+			}
+			key = []byte(s)
+		}
+		i = skipSpace(data, end)
+		if i >= len(data) || data[i] != ':' {
+			return nil, false
+		}
+		i = skipSpace(data, i+1)
+		valEnd, err := skipValue(data, i)
+		if err != nil {
+			return nil, false
+		}
+		switch n := len(key); {
+		case n == len(path) && string(key) == path:
+			exact = i
+		case n < len(path) && path[n] == '.' && string(key) == path[:n]:
+			// duplicate keys are resolved to the last value, like encoding/json does
+			replaced := false
+			for j := range prefixes {
+				if prefixes[j].n == n {
+					prefixes[j].pos = i
+					replaced = true
+				}
+			}
+			if !replaced {
+				prefixes = append(prefixes, lazyPrefix{pos: i, n: n})
+			}
+		}
+		i = valEnd
+	}
+	if exact >= 0 {
+		return l.decode(exact)
+	}
+	// shortest prefix is tried first
+	for len(prefixes) > 0 {
+		min := 0
+		for j := range prefixes {
+			if prefixes[j].n < prefixes[min].n {
+				min = j
+			}
+		}
+		p := prefixes[min]
+		if res, ok := l.resolve(p.pos, path[p.n+1:]); ok {
+			return res, true
+		}
+		prefixes = append(prefixes[:min], prefixes[min+1:]...)
+	}
+	return nil, false
+}
+
+func (l *LazyJSON) resolveArray(i int, path string) (interface{}, bool) {
+	var (
+		data       = l.data
+		head, tail = path, ""
+		out        []interface{}
+		n          int
+	)
+	if j := strings.IndexByte(path, '.'); j >= 0 {
+		head, tail = path[:j], path[j+1:]
+	}
+	idx, err := strconv.Atoi(head)
+	isIndexed := err == nil
+loop:
+	for i++; ; {
+		i = skipSpace(data, i)
+		if i >= len(data) {
+			return nil, false
+		}
+		switch data[i] {
+		case ']':
+			break loop
+		case ',':
+			i++
+			continue loop
+		}
+		end, err := skipValue(data, i)
+		if err != nil {
+			return nil, false
+		}
+		if isIndexed {
+			if n == idx {
+				if tail == "" {
+					return l.decode(i)
+				}
+				return l.resolve(i, tail)
+			}
+		} else if res, ok := l.resolve(i, path); ok {
+			if list, ok := res.([]interface{}); ok {
+				out = append(out, list...)
+			} else {
+				out = append(out, res)
+			}
+		}
+		n++
+		i = end
+	}
+	if len(out) > 0 {
+		return out, true
+	}
+	return nil, false
+}
+
+// decode converts a single JSON value into types produced by encoding/json
+func (l *LazyJSON) decode(i int) (interface{}, bool) {
+	end, err := skipValue(l.data, i)
+	if err != nil || end <= i {
+		return nil, false
+	}
+	raw := l.data[i:end]
+	switch raw[0] {
+	case '"':
+		if _, escaped, err := skipString(raw, 0); err == nil && !escaped {
+			return string(raw[1 : len(raw)-1]), true
+		}
+	case 't':
+		if string(raw) == "true" {
+			return true, true
+		}
+		return nil, false
+	case 'f':
+		if string(raw) == "false" {
+			return false, true
+		}
+		return nil, false
+	case 'n':
+		if string(raw) == "null" {
+			return nil, true
+		}
+		return nil, false
+	case '{', '[':
+	default:
+		f, err := strconv.ParseFloat(string(raw), 64)
+		if err != nil {
+			return nil, false
+		}
+		return f, true
+	}
+	var val interface{}
+	if err := json.Unmarshal(raw, &val); err != nil {
+		return nil, false
+	}
+	return val, true
+}
+
+func skipSpace(data []byte, i int) int {
+	for i < len(data) {
+		switch data[i] {
+		case ' ', '\t', '\n', '\r':
+			i++
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// skipString expects a quote at position i and returns position after closing quote
+func skipString(data []byte, i int) (int, bool, error) {
+	var escaped bool
+	for j := i + 1; j < len(data); j++ {
+		switch data[j] {
+		case '\\':
+			escaped = true
+			j++
+		case '"':
+			return j + 1, escaped, nil
+		}
+	}
+	return len(data), escaped, errLazyJSON
+}
+
+// skipValue returns position after JSON value that starts at position i
+func skipValue(data []byte, i int) (int, error) {
+	if i >= len(data) {
+		return i, errLazyJSON
+	}
+	switch data[i] {
+	case '"':
+		end, _, err := skipString(data, i)
+		return end, err
+	case '{', '[':
+		var depth int
+		for j := i; j < len(data); j++ {
+			switch data[j] {
+			case '"':
+				end, _, err := skipString(data, j)
+				if err != nil {
+					return end, err
+				}
+				j = end - 1
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+				if depth == 0 {
+					return j + 1, nil
+				}
+			}
+		}
+		return len(data), errLazyJSON
+	case ',', '}', ']', ':':
+		return i, errLazyJSON
+	default:
+		j := i
+		for ; j < len(data); j++ {
+			switch data[j] {
+			case ',', '}', ']', ' ', '\t', '\n', '\r':
+				return j, nil
+			}
+		}
+		return j, nil
+	}
+}
@@ -0,0 +1,129 @@
+package sigma
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+var lazyExample = `{
+	"@timestamp": "2020-01-16T10:00:00.000Z",
+	"message": "Process Create:\nRuleName: \"test\"",
+	"event.code": 1,
+	"event": {"category": ["process"], "type": ["start"], "dataset": "sysmon"},
+	"host": {"name": "WS01", "ip": ["10.0.0.5", "fe80::1"], "os": {"family": "windows", "version": "10.0"}},
+	"process": {
+		"name": "cmd.exe",
+		"pid": 1234,
+		"executable": "C:\\Windows\\System32\\cmd.exe",
+		"command_line": "cmd.exe /c \"whoami /all\"",
+		"args": ["cmd.exe", "/c", "whoami /all"],
+		"parent": {"name": "explorer.exe", "pid": 4, "executable": "C:\\Windows\\explorer.exe"},
+		"pe": {"original_file_name": "Cmd.Exe", "company": "Microsoft Corporation"},
+		"hash": {"md5": "d41d8cd98f00b204e9800998ecf8427e", "sha256": "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"}
+	},
+	"user": {"name": "alice", "domain": "CORP", "id": null},
+	"weird\"key": {"nested": true},
+	"dns": {"answers": [{"name": "a", "data": "10.0.0.1"}, {"name": "b", "data": "10.0.0.2"}, {"other": 1}]},
+	"winlog": {"event_data": {"IntegrityLevel": "High", "LogonId": "0x3e7", "Hashes": "MD5=1,SHA256=2"}, "channel": "Microsoft-Windows-Sysmon/Operational"},
+	"empty": {},
+	"list": [],
+	"numbers": [1, 2.5, -3e2]
+}`
+
+var lazyPaths = []string{
+	"@timestamp",
+	"message",
+	"event.code",
+	"event.category",
+	"event",
+	"host.name",
+	"host.ip",
+	"host.ip.1",
+	"host.os.family",
+	"process.command_line",
+	"process.executable",
+	"process.args.2",
+	"process.args",
+	"process.parent.name",
+	"process.parent.pid",
+	"process.pe.original_file_name",
+	"process.hash.sha256",
+	"user.id",
+	"user.missing",
+	`weird"key.nested`,
+	"dns.answers.data",
+	"dns.answers.0.name",
+	"dns.answers.5.name",
+	"dns.answers.other",
+	"winlog.event_data.IntegrityLevel",
+	"winlog.channel",
+	"empty",
+	"empty.key",
+	"list",
+	"list.0",
+	"numbers",
+	"numbers.2",
+	"missing",
+	"process.name.extra",
+}
+
+func TestLazyJSONEquivalence(t *testing.T) {
+	full, err := NewDynamicMapFromJSON([]byte(lazyExample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lazy := NewLazyJSON([]byte(lazyExample))
+	for i := 0; i < 2; i++ {
+		// second pass is served from cache
+		for _, path := range lazyPaths {
+			v1, ok1 := full.GetField(path)
+			v2, ok2 := lazy.GetField(path)
+			if ok1 != ok2 || !reflect.DeepEqual(v1, v2) {
+				t.Fatalf("%s: map returned %v %v, lazy returned %v %v", path, v1, ok1, v2, ok2)
+			}
+		}
+	}
+	if !reflect.DeepEqual(full.GetMessage(), lazy.GetMessage()) {
+		t.Fatalf("message mismatch: %+v %+v", full.GetMessage(), lazy.GetMessage())
+	}
+	lazy.Reset([]byte(`{"process": {"name": "powershell.exe"}}`))
+	if val, ok := lazy.GetField("process.name"); !ok || val != "powershell.exe" {
+		t.Fatalf("reset did not clear cache, got %v", val)
+	}
+	lazy.Reset([]byte(`{"process": {"name": "powershell.exe"`))
+	if _, ok := lazy.GetField("process.pid"); ok {
+		t.Fatal("malformed JSON should not return fields")
+	}
+}
+
+var lazyBenchRule = Detection{
+	"condition": "selection",
+	"selection": map[string]interface{}{
+		"process.parent.name":  "explorer.exe",
+		"process.command_line": "*whoami*",
+		"event.code":           1,
+	},
+}
+
+func BenchmarkDynamicMapCheck(b *testing.B) {
+	tree, _ := ParseDetection(lazyBenchRule)
+	data := []byte(lazyExample)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var obj map[string]interface{}
+		json.Unmarshal(data, &obj)
+		tree.Match(NewDynamicMap(obj))
+	}
+}
+
+func BenchmarkLazyJSONCheck(b *testing.B) {
+	tree, _ := ParseDetection(lazyBenchRule)
+	data := []byte(lazyExample)
+	obj := NewLazyJSON(nil)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		obj.Reset(data)
+		tree.Match(obj)
+	}
+}
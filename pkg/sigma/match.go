@@ -0,0 +1,103 @@
+package sigma
+
+// MatchDetails evaluates the tree like Match and also returns detection identifiers that made the event match
+// Selections under a negation never contribute, as they matched by not matching
+// Regular Match should be preferred when details are not needed, as recording allocates
+func (t Tree) MatchDetails(obj EventChecker) (bool, []MatchedSelection) {
+	rec := make([]MatchedSelection, 0)
+	if !matchRecord(t.Root, obj, &rec) {
+		return false, nil
+	}
+	return true, rec
+}
+
+// matchRecord walks the tree with same short circuit logic as Match
+// Records of branches that did not match are discarded, rec may be nil to disable recording
+func matchRecord(b Branch, obj EventChecker, rec *[]MatchedSelection) bool {
+	switch n := b.(type) {
+	case NodeSimpleAnd:
+		for _, elem := range n {
+			if !matchRecordOrReset(elem, obj, rec) {
+				return false
+			}
+		}
+		return true
+	case NodeSimpleOr:
+		for _, elem := range n {
+			if matchRecordOrReset(elem, obj, rec) {
+				return true
+			}
+		}
+		return false
+	case NodeAnd:
+		return matchRecordOrReset(n.L, obj, rec) && matchRecordOrReset(n.R, obj, rec)
+	case NodeOr:
+		return matchRecordOrReset(n.L, obj, rec) || matchRecordOrReset(n.R, obj, rec)
+	case NodeNot:
+		return !matchRecord(n.Branch, obj, nil)
+	case *Keyword:
+		if rec == nil {
+			return n.Match(obj)
+		}
+		matched := make([]string, 0)
+		for _, msg := range obj.GetMessage() {
+			if matchKeywords(n.stringPatterns, n.toLower, msg) {
+				matched = append(matched, msg)
+			}
+		}
+		if !n.record(len(matched) > 0) {
+			return false
+		}
+		*rec = append(*rec, MatchedSelection{Name: n.ident, Keywords: matched})
+		return true
+	case *Fields:
+		if !n.Match(obj) {
+			return false
+		}
+		if rec != nil {
+			*rec = append(*rec, MatchedSelection{Name: n.ident, Fields: n.values(obj)})
+		}
+		return true
+	case FieldsList:
+		for _, f := range n {
+			if matchRecord(f, obj, rec) {
+				return true
+			}
+		}
+		return false
+	default:
+		return b.Match(obj)
+	}
+}
+
+func matchRecordOrReset(b Branch, obj EventChecker, rec *[]MatchedSelection) bool {
+	if rec == nil {
+		return matchRecord(b, obj, nil)
+	}
+	l := len(*rec)
+	if !matchRecord(b, obj, rec) {
+		*rec = (*rec)[:l]
+		return false
+	}
+	return true
+}
+
+// values collects event values for every field referenced by selection
+func (f *Fields) values(obj EventChecker) map[string]interface{} {
+	out := make(map[string]interface{})
+	add := func(field string) {
+		if val, ok := obj.GetField(field); ok {
+			out[field] = val
+		}
+	}
+	for field := range f.sPatterns {
+		add(field)
+	}
+	for field := range f.nPatterns {
+		add(field)
+	}
+	for field := range f.bPatterns {
+		add(field)
+	}
+	return out
+}
@@ -0,0 +1,69 @@
+package sigma
+
+import (
+	"reflect"
+	"testing"
+)
+
+// messageObject uses Message field for keyword rules
+type messageObject map[string]interface{}
+
+func (m messageObject) GetMessage() []string {
+	if msg, ok := m["Message"].(string); ok {
+		return []string{msg}
+	}
+	return nil
+}
+
+func (m messageObject) GetField(key string) (interface{}, bool) {
+	val, ok := m[key]
+	return val, ok
+}
+
+func TestTreeMatchDetails(t *testing.T) {
+	tree, err := ParseDetection(Detection{
+		"selection_img": map[interface{}]interface{}{
+			"Image|endswith": []interface{}{`\cmd.exe`, `\powershell.exe`},
+		},
+		"selection_cli": []interface{}{
+			map[interface{}]interface{}{"CommandLine|contains": "whoami"},
+			map[interface{}]interface{}{"CommandLine|contains": "hostname", "ParentPid": 4},
+		},
+		"filter":    map[interface{}]interface{}{"User": "SYSTEM"},
+		"keywords":  []interface{}{"recon"},
+		"condition": "(selection_img and selection_cli and not filter) or keywords",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		event messageObject
+		match bool
+		sel   []MatchedSelection
+	}{
+		{
+			event: messageObject{"Image": `C:\cmd.exe`, "CommandLine": "hostname", "ParentPid": 4, "User": "bob"},
+			match: true,
+			sel: []MatchedSelection{
+				{Name: "selection_img", Fields: map[string]interface{}{"Image": `C:\cmd.exe`}},
+				{Name: "selection_cli", Fields: map[string]interface{}{"CommandLine": "hostname", "ParentPid": 4}},
+			},
+		},
+		{
+			event: messageObject{"Image": `C:\cmd.exe`, "CommandLine": "whoami", "User": "SYSTEM", "Message": "recon tool"},
+			match: true,
+			sel:   []MatchedSelection{{Name: "keywords", Keywords: []string{"recon tool"}}},
+		},
+		{
+			event: messageObject{"Image": `C:\cmd.exe`, "CommandLine": "whoami", "User": "SYSTEM"},
+		},
+	} {
+		ok, sel := tree.MatchDetails(tc.event)
+		if ok != tc.match || ok != tree.Match(tc.event) {
+			t.Fatalf("%+v: expected match %t", tc.event, tc.match)
+		}
+		if !reflect.DeepEqual(sel, tc.sel) {
+			t.Fatalf("%+v: expected %+v, got %+v", tc.event, tc.sel, sel)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package sigma
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MetricsContentType is the Prometheus text exposition format served by Metrics
+const MetricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Metrics collects engine counters of rulesets loaded with it and exposes them in Prometheus text format
+// Engine counters survive ruleset reloads, rule counters are reported for the latest loaded ruleset only
+// Metrics implements http.Handler, so it can be served on a metrics endpoint without a metrics client library
+type Metrics struct {
+	events       int64
+	matches      [LevelCritical + 1]int64
+	loads        int64
+	loadFailures int64
+
+	mu       sync.RWMutex
+	ruleset  *Ruleset
+	loadedAt time.Time
+}
+
+// NewMetrics creates an empty collector, pass it to NewRuleset with Config.Metrics
+func NewMetrics() *Metrics { return &Metrics{} }
+
+// loaded records the outcome of NewRuleset, failed loads keep previous ruleset
+func (m *Metrics) loaded(r *Ruleset, err error) {
+	if err != nil {
+		atomic.AddInt64(&m.loadFailures, 1)
+		return
+	}
+	atomic.AddInt64(&m.loads, 1)
+	m.mu.Lock()
+	m.ruleset = r
+	m.loadedAt = time.Now()
+	m.mu.Unlock()
+}
+
+// LoadFailed counts a ruleset load that failed before NewRuleset was called, for example on invalid configuration
+func (m *Metrics) LoadFailed() { atomic.AddInt64(&m.loadFailures, 1) }
+
+// checked records an evaluated event and its results
+func (m *Metrics) checked(res Results) {
+	atomic.AddInt64(&m.events, 1)
+	for _, r := range res {
+		l := r.Level
+		if l < LevelUnknown || l > LevelCritical {
+			l = LevelUnknown
+		}
+		atomic.AddInt64(&m.matches[l], 1)
+	}
+}
+
+// ServeHTTP implements http.Handler
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var buf bytes.Buffer
+	m.WriteTo(&buf)
+	w.Header().Set("Content-Type", MetricsContentType)
+	w.Write(buf.Bytes())
+}
+
+// WriteTo writes all metrics in Prometheus text exposition format
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.RLock()
+	r, loadedAt := m.ruleset, m.loadedAt
+	m.mu.RUnlock()
+
+	e := &metricsWriter{w: bufio.NewWriter(w)}
+
+	e.family("sigma_events_total", "counter", "Events evaluated against rules.")
+	e.sample("sigma_events_total", nil, float64(atomic.LoadInt64(&m.events)))
+
+	e.family("sigma_matches_total", "counter", "Rule matches by rule level.")
+	for l := range m.matches {
+		e.sample("sigma_matches_total", []string{"level", levelLabel(Level(l))}, float64(atomic.LoadInt64(&m.matches[l])))
+	}
+
+	e.family("sigma_ruleset_loads_total", "counter", "Successful ruleset loads, including reloads.")
+	e.sample("sigma_ruleset_loads_total", nil, float64(atomic.LoadInt64(&m.loads)))
+
+	e.family("sigma_ruleset_load_failures_total", "counter", "Ruleset loads that returned an error.")
+	e.sample("sigma_ruleset_load_failures_total", nil, float64(atomic.LoadInt64(&m.loadFailures)))
+
+	if r == nil {
+		return e.n, e.err()
+	}
+
+	e.family("sigma_ruleset_last_load_timestamp_seconds", "gauge", "Time of last successful ruleset load.")
+	e.sample("sigma_ruleset_last_load_timestamp_seconds", nil, float64(loadedAt.UnixNano())/1e9)
+
+	e.family("sigma_rules", "gauge", "Rules in loaded ruleset by state.")
+	e.sample("sigma_rules", []string{"state", "loaded"}, float64(r.Total))
+	e.sample("sigma_rules", []string{"state", "broken"}, float64(len(r.Broken)))
+	e.sample("sigma_rules", []string{"state", "unsupported"}, float64(len(r.Unsupported)))
+
+	stats := r.Stats()
+	labels := make([][]string, len(stats))
+	for i, s := range stats {
+		labels[i] = []string{"id", s.ID, "title", s.Title, "path", s.Path}
+	}
+
+	e.family("sigma_rule_evaluations_total", "counter", "Evaluations of a rule.")
+	for i, s := range stats {
+		e.sample("sigma_rule_evaluations_total", labels[i], float64(s.Evaluations))
+	}
+
+	e.family("sigma_rule_matches_total", "counter", "Matches of a rule.")
+	for i, s := range stats {
+		e.sample("sigma_rule_matches_total", labels[i], float64(s.Hits))
+	}
+
+	e.family("sigma_rule_evaluation_duration_seconds", "summary", "Sampled rule evaluation latency.")
+	for i, s := range stats {
+		for _, q := range []struct {
+			quantile string
+			value    time.Duration
+		}{
+			{quantile: "0.5", value: s.Latency.P50},
+			{quantile: "0.9", value: s.Latency.P90},
+			{quantile: "0.99", value: s.Latency.P99},
+		} {
+			value := q.value.Seconds()
+			if s.Latency.Samples == 0 {
+				value = math.NaN()
+			}
+			e.sample("sigma_rule_evaluation_duration_seconds", append(labels[i], "quantile", q.quantile), value)
+		}
+		e.sample("sigma_rule_evaluation_duration_seconds_sum", labels[i], s.Latency.Sum.Seconds())
+		e.sample("sigma_rule_evaluation_duration_seconds_count", labels[i], float64(s.Latency.Samples))
+	}
+	return e.n, e.err()
+}
+
+func levelLabel(l Level) string {
+	if l == LevelUnknown {
+		return "unknown"
+	}
+	return l.String()
+}
+
+// metricsWriter formats text exposition lines and keeps the first write error
+type metricsWriter struct {
+	w     *bufio.Writer
+	n     int64
+	werr  error
+	label strings.Builder
+}
+
+func (e *metricsWriter) write(s string) {
+	if e.werr != nil {
+		return
+	}
+	n, err := e.w.WriteString(s)
+	e.n += int64(n)
+	e.werr = err
+}
+
+func (e *metricsWriter) err() error {
+	if e.werr != nil {
+		return e.werr
+	}
+	return e.w.Flush()
+}
+
+func (e *metricsWriter) family(name, kind, help string) {
+	e.write("# HELP " + name + " " + help + "\n")
+	e.write("# TYPE " + name + " " + kind + "\n")
+}
+
+// sample writes a single metric line, labels are given as name and value pairs
+func (e *metricsWriter) sample(name string, labels []string, value float64) {
+	e.label.Reset()
+	e.label.WriteString(name)
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i == 0 {
+			e.label.WriteByte('{')
+		} else {
+			e.label.WriteByte(',')
+		}
+		e.label.WriteString(labels[i])
+		e.label.WriteString(`="`)
+		e.label.WriteString(escapeLabel(labels[i+1]))
+		e.label.WriteByte('"')
+	}
+	if len(labels) > 1 {
+		e.label.WriteByte('}')
+	}
+	e.label.WriteByte(' ')
+	e.label.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	e.label.WriteByte('\n')
+	e.write(e.label.String())
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
@@ -0,0 +1,77 @@
+package sigma
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	dirs, cleanup := newTestRuleDirs(t, []testRuleFile{
+		{name: "a.yml", id: testID1, title: `say "whoami"`, pattern: "*whoami*"},
+		{name: "b.yml", id: testID2, title: "never", pattern: "never"},
+	})
+	defer cleanup()
+
+	m := NewMetrics()
+	r, err := NewRuleset(&Config{Directories: dirs, Metrics: m})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cmd := range []string{"whoami", "dir", "whoami /all"} {
+		r.Check(dummyObject{"CommandLine": cmd}, Logsource{Product: "windows"}, false)
+	}
+	// reload keeps engine counters and replaces rule counters
+	r, err = NewRuleset(&Config{Directories: dirs, Metrics: m})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Check(dummyObject{"CommandLine": "whoami"}, Logsource{Product: "windows"}, false)
+
+	empty, err := ioutil.TempDir("", "sigma-empty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(empty)
+	if _, err := NewRuleset(&Config{Directories: []string{empty}, Metrics: m}); err == nil {
+		t.Fatal("empty directory should fail to load")
+	}
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != MetricsContentType {
+		t.Fatalf("wrong content type %s", ct)
+	}
+	out := rec.Body.String()
+	labels := `{id="` + testID1 + `",title="say \"whoami\"",path="` + strings.Replace(dirs[0], `\`, `\\`, -1) + `/a.yml"}`
+	for _, line := range []string{
+		"# TYPE sigma_events_total counter",
+		"sigma_events_total 4",
+		`sigma_matches_total{level="high"} 3`,
+		`sigma_matches_total{level="low"} 0`,
+		"sigma_ruleset_loads_total 2",
+		"sigma_ruleset_load_failures_total 1",
+		`sigma_rules{state="loaded"} 2`,
+		`sigma_rules{state="broken"} 0`,
+		"sigma_rule_evaluations_total" + labels + " 1",
+		"sigma_rule_matches_total" + labels + " 1",
+		"# TYPE sigma_rule_evaluation_duration_seconds summary",
+		"sigma_rule_evaluation_duration_seconds_count" + labels + " 1",
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Fatalf("missing %s from\n%s", line, out)
+		}
+	}
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		value := line[strings.LastIndex(line, " ")+1:]
+		if _, err := strconv.ParseFloat(value, 64); err != nil || !strings.HasPrefix(line, "sigma_") {
+			t.Fatalf("malformed sample %s", line)
+		}
+	}
+}
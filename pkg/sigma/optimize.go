@@ -0,0 +1,140 @@
+package sigma
+
+import (
+	"sort"
+)
+
+// Relative cost of evaluating a single pattern, used for ordering
+const (
+	costScalar  = 1
+	costLiteral = 2
+	costGlob    = 4
+	costRegex   = 16
+	// keywords are applied to every message of the event, so the same pattern costs more than a field lookup
+	costKeywordFactor = 2
+)
+
+// Optimize rewrites a compiled tree into an equivalent one that is cheaper to evaluate
+//   - nested and and or nodes are flattened into NodeSimpleAnd and NodeSimpleOr
+//   - double negation is removed
+//   - identical operands of the same node are evaluated once
+//   - operands are ordered by estimated cost, so short circuit skips expensive regular expressions and globs
+//
+// Selection leaves are not modified, so trees share them with the original
+func Optimize(b Branch) Branch {
+	switch n := b.(type) {
+	case Tree:
+		return Optimize(n.Root)
+	case *Tree:
+		return Optimize(n.Root)
+	case NodeSimpleAnd:
+		return optimizeOperands(true, n...)
+	case NodeAnd:
+		return optimizeOperands(true, n.L, n.R)
+	case NodeSimpleOr:
+		return optimizeOperands(false, n...)
+	case NodeOr:
+		return optimizeOperands(false, n.L, n.R)
+	case NodeNot:
+		child := Optimize(n.Branch)
+		if not, ok := child.(NodeNot); ok {
+			return not.Branch
+		}
+		return NodeNot{Branch: child}
+	}
+	return b
+}
+
+// Optimize returns a new tree with optimized root, see Optimize
+func (t Tree) Optimize() *Tree {
+	return &Tree{Root: Optimize(t.Root)}
+}
+
+func optimizeOperands(and bool, operands ...Branch) Branch {
+	flat := make([]Branch, 0, len(operands))
+	var add func(b Branch)
+	add = func(b Branch) {
+		switch n := b.(type) {
+		case NodeSimpleAnd:
+			if and {
+				for _, child := range n {
+					add(child)
+				}
+				return
+			}
+		case NodeSimpleOr:
+			if !and {
+				for _, child := range n {
+					add(child)
+				}
+				return
+			}
+		}
+		for _, seen := range flat {
+			if sameOperand(seen, b) {
+				return
+			}
+		}
+		flat = append(flat, b)
+	}
+	for _, b := range operands {
+		add(Optimize(b))
+	}
+	if len(flat) == 1 {
+		return flat[0]
+	}
+	sort.SliceStable(flat, func(i, j int) bool { return cost(flat[i]) < cost(flat[j]) })
+	if and {
+		return NodeSimpleAnd(flat)
+	}
+	return NodeSimpleOr(flat)
+}
+
+// sameOperand reports if both branches evaluate the same selection
+// Named selections are compared by ident, as every reference to a selection of a rule is parsed into its own leaf
+// Anonymous leaves are compared by identity and nested nodes are never treated as equal
+func sameOperand(a, b Branch) bool {
+	if na, ok := a.(NodeNot); ok {
+		nb, ok := b.(NodeNot)
+		return ok && sameOperand(na.Branch, nb.Branch)
+	}
+	ia, okA := a.(interface{ Ident() string })
+	ib, okB := b.(interface{ Ident() string })
+	if okA && okB && ia.Ident() != "" {
+		return ia.Ident() == ib.Ident()
+	}
+	switch a.(type) {
+	case *Fields, *Keyword:
+		return a == b
+	}
+	return false
+}
+
+// cost estimates relative evaluation cost of a branch
+func cost(b Branch) int {
+	switch n := b.(type) {
+	case *Fields:
+		c := costScalar * len(n.nulls)
+		for _, p := range n.nPatterns {
+			c += costScalar * len(p)
+		}
+		for _, p := range n.bPatterns {
+			c += costScalar * len(p)
+		}
+		for _, p := range n.sPatterns {
+			c += p.cost()
+		}
+		return c
+	case *Keyword:
+		return n.stringPatterns.cost() * costKeywordFactor
+	}
+	c := 0
+	for _, child := range children(b) {
+		c += cost(child)
+	}
+	return c
+}
+
+func (s stringPatterns) cost() int {
+	return costLiteral*len(s.literals) + costGlob*len(s.globs) + costRegex*len(s.re)
+}
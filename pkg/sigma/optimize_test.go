@@ -0,0 +1,157 @@
+package sigma
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func optimizeTestLeaves(t *testing.T) []Branch {
+	leaves := make([]Branch, 0)
+	for name, raw := range map[string]map[string]interface{}{
+		"num":     {"EventID": 1},
+		"literal": {"Image": "cmd.exe"},
+		"glob":    {"CommandLine": "*whoami*"},
+		"regex":   {"CommandLine": "/net\\s+user/"},
+		"mixed":   {"EventID": []interface{}{1, 2}, "User": "/^adm/"},
+		"null":    {"Parent": nil},
+	} {
+		f, err := NewFields(raw, false, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.ident = name
+		leaves = append(leaves, f)
+	}
+	kw, err := NewKeyword(false, "recon", "*mimikatz*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kw.ident = "keywords"
+	return append(leaves, kw)
+}
+
+func randomBranch(r *rand.Rand, leaves []Branch, depth int) Branch {
+	if depth == 0 || r.Intn(4) == 0 {
+		return leaves[r.Intn(len(leaves))]
+	}
+	switch r.Intn(5) {
+	case 0:
+		return NodeAnd{L: randomBranch(r, leaves, depth-1), R: randomBranch(r, leaves, depth-1)}
+	case 1:
+		return NodeOr{L: randomBranch(r, leaves, depth-1), R: randomBranch(r, leaves, depth-1)}
+	case 2:
+		return NodeNot{Branch: randomBranch(r, leaves, depth-1)}
+	}
+	n := make([]Branch, 1+r.Intn(3))
+	for i := range n {
+		n[i] = randomBranch(r, leaves, depth-1)
+	}
+	if r.Intn(2) == 0 {
+		return NodeSimpleAnd(n)
+	}
+	return NodeSimpleOr(n)
+}
+
+func randomEvent(r *rand.Rand) messageObject {
+	e := messageObject{}
+	pick := func(vals ...interface{}) interface{} { return vals[r.Intn(len(vals))] }
+	if r.Intn(2) == 0 {
+		e["EventID"] = pick(1, 2, "1", 3.0)
+	}
+	if r.Intn(2) == 0 {
+		e["Image"] = pick(`C:\cmd.exe`, "bash")
+	}
+	if r.Intn(2) == 0 {
+		e["CommandLine"] = pick("cmd /c whoami", "net  user x", "ls")
+	}
+	if r.Intn(2) == 0 {
+		e["User"] = pick("admin", "bob")
+	}
+	if r.Intn(2) == 0 {
+		e["Parent"] = pick(nil, "explorer.exe")
+	}
+	if r.Intn(2) == 0 {
+		e["Message"] = pick("recon", "run mimikatz now", "nothing")
+	}
+	return e
+}
+
+func TestOptimizeEquivalence(t *testing.T) {
+	leaves := optimizeTestLeaves(t)
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		tree := Tree{Root: randomBranch(r, leaves, 4)}
+		optimized := tree.Optimize()
+		for j := 0; j < 20; j++ {
+			e := randomEvent(r)
+			if tree.Match(e) != optimized.Match(e) {
+				t.Fatalf("%s optimized to %s differs for %+v", tree, optimized, e)
+			}
+		}
+	}
+}
+
+func TestOptimize(t *testing.T) {
+	tree, err := ParseDetection(Detection{
+		"sel_regex": map[interface{}]interface{}{"CommandLine": "/whoami/"},
+		"sel_glob":  map[interface{}]interface{}{"Image": "*cmd*"},
+		"sel_num":   map[interface{}]interface{}{"EventID": 1},
+		"sel_lit":   map[interface{}]interface{}{"User": "bob"},
+		"filter":    map[interface{}]interface{}{"Parent": "explorer.exe"},
+		"condition": "sel_regex and (sel_glob and (sel_num and sel_lit)) and not (not filter) and sel_num",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := tree.Optimize().String(); s != "sel_num and sel_lit and filter and sel_glob and sel_regex" {
+		t.Fatalf("unexpected optimized tree %s", s)
+	}
+	tree, err = ParseDetection(Detection{
+		"a":         map[interface{}]interface{}{"x": "/a/"},
+		"b":         map[interface{}]interface{}{"y": 1},
+		"condition": "(a or b) or (a or not (not b))",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := tree.Optimize().String(); s != "b or a" {
+		t.Fatalf("unexpected optimized tree %s", s)
+	}
+
+	// anonymous leaves are only deduplicated when they are the same leaf
+	x, err := NewFields(map[string]interface{}{"EventID": 1}, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	y, err := NewFields(map[string]interface{}{"EventID": 1}, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, ok := Optimize(NodeSimpleAnd{x, y, x, NodeNot{Branch: x}}).(NodeSimpleAnd); !ok || len(n) != 3 {
+		t.Fatalf("expected 3 operands, got %#v", n)
+	}
+}
+
+func BenchmarkOptimize(b *testing.B) {
+	tree, err := ParseDetection(Detection{
+		"sel_regex": map[interface{}]interface{}{"CommandLine": "/(?i)invoke-(mimikatz|expression)/"},
+		"sel_glob":  map[interface{}]interface{}{"Image": "*\\powershell.exe"},
+		"sel_num":   map[interface{}]interface{}{"EventID": 1},
+		"condition": "sel_regex and sel_glob and sel_num",
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	e := dummyObject{"EventID": 3, "Image": `C:\Windows\powershell.exe`, "CommandLine": "powershell -enc AAAA"}
+	b.Run("source", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			tree.Match(e)
+		}
+	})
+	optimized := tree.Optimize()
+	b.Run("optimized", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			optimized.Match(e)
+		}
+	})
+}
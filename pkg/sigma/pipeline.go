@@ -0,0 +1,211 @@
+package sigma
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Pipeline translates rules from sigma taxonomy into the schema of events that are being matched
+// It is applied once when rules are loaded, so matching cost is not affected
+type Pipeline struct {
+	Name string `yaml:"name" json:"name"`
+	// Version of target schema or mapping table
+	Version string `yaml:"version" json:"version"`
+
+	// FieldMappings renames rule fields, keys are field names used in sigma rules
+	FieldMappings map[string]string `yaml:"fieldmappings" json:"fieldmappings"`
+
+	// Logsources rewrites logsource and adds conditions to matching rules
+	// Only the first matching entry is applied
+	Logsources []LogsourceMapping `yaml:"logsources" json:"logsources"`
+
+	// DropUnmapped marks rules that reference fields missing from FieldMappings as unsupported
+	DropUnmapped bool `yaml:"drop_unmapped" json:"drop_unmapped"`
+}
+
+// LogsourceMapping maps sigma logsource to the product, category and service of consumed events
+type LogsourceMapping struct {
+	// Match selects rules by logsource, empty values act as wildcards
+	Match Logsource `yaml:"match" json:"match"`
+	// Rewrite replaces non-empty values in rule logsource
+	Rewrite Logsource `yaml:"rewrite" json:"rewrite"`
+	// FieldMappings take precedence over pipeline field mappings for matching rules
+	FieldMappings map[string]string `yaml:"fieldmappings" json:"fieldmappings"`
+	// Conditions are field selections that are joined to rule detection with logical AND
+	// Field names are not translated by FieldMappings
+	Conditions map[string]interface{} `yaml:"conditions" json:"conditions"`
+}
+
+func (l LogsourceMapping) match(ls Logsource) bool {
+	m, ls := l.Match.key(), ls.key()
+	return (m.Product == "" || m.Product == ls.Product) &&
+		(m.Category == "" || m.Category == ls.Category) &&
+		(m.Service == "" || m.Service == ls.Service)
+}
+
+func (l LogsourceMapping) rewrite(ls Logsource) Logsource {
+	if l.Rewrite.Product != "" {
+		ls.Product = l.Rewrite.Product
+	}
+	if l.Rewrite.Category != "" {
+		ls.Category = l.Rewrite.Category
+	}
+	if l.Rewrite.Service != "" {
+		ls.Service = l.Rewrite.Service
+	}
+	return ls
+}
+
+// NewPipeline parses pipeline from YAML
+func NewPipeline(data []byte) (*Pipeline, error) {
+	var p Pipeline
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, err
+	}
+	for i, ls := range p.Logsources {
+		if ls.Conditions == nil {
+			continue
+		}
+		if _, err := newFields(ls.Conditions, false, true, nil); err != nil {
+			return nil, fmt.Errorf("pipeline %s logsource mapping %d has invalid conditions: %s", p.Name, i, err)
+		}
+	}
+	return &p, nil
+}
+
+// NewPipelineFromFile parses pipeline from YAML file
+func NewPipelineFromFile(path string) (*Pipeline, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p, err := NewPipeline(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	if p.Name == "" {
+		p.Name = path
+	}
+	return p, nil
+}
+
+// Apply returns a copy of rule translated by pipeline and conditions that should be joined to its detection
+// ErrUnmappedFields is returned if DropUnmapped is set and rule references fields with no mapping
+func (p Pipeline) Apply(rule RawRule) (RawRule, []map[string]interface{}, error) {
+	var logsource *LogsourceMapping
+	for i, ls := range p.Logsources {
+		if ls.match(rule.Logsource) {
+			logsource = &p.Logsources[i]
+			break
+		}
+	}
+	unmapped := make(map[string]bool)
+	rule.Detection = renameDetectionFields(rule.Detection, func(field string) string {
+		if logsource != nil {
+			if mapped, ok := logsource.FieldMappings[field]; ok {
+				return mapped
+			}
+		}
+		if mapped, ok := p.FieldMappings[field]; ok {
+			return mapped
+		}
+		unmapped[field] = true
+		return field
+	})
+	if p.DropUnmapped && len(unmapped) > 0 {
+		fields := make([]string, 0, len(unmapped))
+		for field := range unmapped {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		return rule, nil, ErrUnmappedFields{Pipeline: p.Name, Fields: fields}
+	}
+	if logsource == nil {
+		return rule, nil, nil
+	}
+	rule.Logsource = logsource.rewrite(rule.Logsource)
+	if len(logsource.Conditions) > 0 {
+		return rule, []map[string]interface{}{logsource.Conditions}, nil
+	}
+	return rule, nil, nil
+}
+
+// renameDetectionFields returns a copy of detection with selection field names translated by rename function
+// Field modifiers such as |endswith are preserved
+func renameDetectionFields(d Detection, rename func(string) string) Detection {
+	if d == nil {
+		return nil
+	}
+	out := make(Detection, len(d))
+	for k, v := range d {
+		if k == "condition" {
+			out[k] = v
+			continue
+		}
+		if (&SearchExpr{Name: k}).Guess().Type == ExprKeywords {
+			out[k] = v
+			continue
+		}
+		out[k] = renameSelectionFields(v, rename)
+	}
+	return out
+}
+
+func renameSelectionFields(v interface{}, rename func(string) string) interface{} {
+	key := func(field string) string {
+		if i := strings.Index(field, "|"); i >= 0 {
+			return rename(field[:i]) + field[i:]
+		}
+		return rename(field)
+	}
+	switch sel := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(sel))
+		for field, val := range sel {
+			out[key(field)] = val
+		}
+		return out
+	case map[interface{}]interface{}:
+		out := make(map[interface{}]interface{}, len(sel))
+		for field, val := range sel {
+			if str, ok := field.(string); ok {
+				out[key(str)] = val
+			} else {
+				out[field] = val
+			}
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(sel))
+		for i, item := range sel {
+			out[i] = renameSelectionFields(item, rename)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// withConditions joins additional field selections to tree with logical AND
+// Selections are built like rule selections, sharing regex budget of the rule
+// Each selection is named pipeline_condition_N, prefixed with underscores if detection already uses the name,
+// so the tree renders as a valid condition
+func withConditions(t *Tree, detection Detection, conditions []map[string]interface{}, budget *regexBudget) (*Tree, error) {
+	branch := NodeSimpleAnd{}
+	for i, c := range conditions {
+		f, err := newFields(c, false, true, budget)
+		if err != nil {
+			return nil, err
+		}
+		f.ident = fmt.Sprintf("pipeline_condition_%d", i+1)
+		for _, ok := detection[f.ident]; ok; _, ok = detection[f.ident] {
+			f.ident = "_" + f.ident
+		}
+		branch = append(branch, f)
+	}
+	return &Tree{Root: append(branch, t.Root)}, nil
+}
@@ -0,0 +1,289 @@
+package sigma
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// BundledPipelines lists names of mapping tables shipped with the library
+func BundledPipelines() []string {
+	out := make([]string, 0, len(bundledPipelines))
+	for name := range bundledPipelines {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// BundledPipeline returns a mapping table shipped with the library
+// Name may be suffixed with @version to ensure that expected version of the table is used, for example ecs@8.11
+func BundledPipeline(name string) (*Pipeline, error) {
+	var version string
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, version = name[:i], name[i+1:]
+	}
+	data, ok := bundledPipelines[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown bundled pipeline %s, options are %s",
+			name, strings.Join(BundledPipelines(), ", "))
+	}
+	p, err := NewPipeline([]byte(data))
+	if err != nil {
+		return nil, fmt.Errorf("bundled pipeline %s: %s", name, err)
+	}
+	if version != "" && version != p.Version {
+		return nil, fmt.Errorf("bundled pipeline %s has version %s, requested %s", name, p.Version, version)
+	}
+	return p, nil
+}
+
+var bundledPipelines = map[string]string{
+	"sysmon":           pipelineSysmon,
+	"windows-security": pipelineWindowsSecurity,
+	"ecs":              pipelineECS,
+	"ocsf":             pipelineOCSF,
+}
+
+// Sigma windows taxonomy is based on sysmon, so fields are kept as-is
+// Generic categories are mapped to sysmon service and event IDs
+const pipelineSysmon = `
+name: sysmon
+version: "15.0"
+logsources:
+  - match: {product: windows, category: process_creation}
+    rewrite: {service: sysmon}
+    conditions: {EventID: 1}
+  - match: {product: windows, category: file_change}
+    rewrite: {service: sysmon}
+    conditions: {EventID: 2}
+  - match: {product: windows, category: network_connection}
+    rewrite: {service: sysmon}
+    conditions: {EventID: 3}
+  - match: {product: windows, category: sysmon_status}
+    rewrite: {service: sysmon}
+    conditions: {EventID: [4, 16]}
+  - match: {product: windows, category: process_termination}
+    rewrite: {service: sysmon}
+    conditions: {EventID: 5}
+  - match: {product: windows, category: driver_load}
+    rewrite: {service: sysmon}
+    conditions: {EventID: 6}
+  - match: {product: windows, category: image_load}
+    rewrite: {service: sysmon}
+    conditions: {EventID: 7}
+  - match: {product: windows, category: create_remote_thread}
+    rewrite: {service: sysmon}
+    conditions: {EventID: 8}
+  - match: {product: windows, category: raw_access_thread}
+    rewrite: {service: sysmon}
+    conditions: {EventID: 9}
+  - match: {product: windows, category: process_access}
+    rewrite: {service: sysmon}
+    conditions: {EventID: 10}
+  - match: {product: windows, category: file_event}
+    rewrite: {service: sysmon}
+    conditions: {EventID: 11}
+  - match: {product: windows, category: registry_add}
+    rewrite: {service: sysmon}
+    conditions: {EventID: 12}
+  - match: {product: windows, category: registry_delete}
+    rewrite: {service: sysmon}
+    conditions: {EventID: 12}
+  - match: {product: windows, category: registry_set}
+    rewrite: {service: sysmon}
+    conditions: {EventID: 13}
+  - match: {product: windows, category: registry_rename}
+    rewrite: {service: sysmon}
+    conditions: {EventID: 14}
+  - match: {product: windows, category: registry_event}
+    rewrite: {service: sysmon}
+    conditions: {EventID: [12, 13, 14]}
+  - match: {product: windows, category: create_stream_hash}
+    rewrite: {service: sysmon}
+    conditions: {EventID: 15}
+  - match: {product: windows, category: pipe_created}
+    rewrite: {service: sysmon}
+    conditions: {EventID: [17, 18]}
+  - match: {product: windows, category: wmi_event}
+    rewrite: {service: sysmon}
+    conditions: {EventID: [19, 20, 21]}
+  - match: {product: windows, category: dns_query}
+    rewrite: {service: sysmon}
+    conditions: {EventID: 22}
+  - match: {product: windows, category: file_delete}
+    rewrite: {service: sysmon}
+    conditions: {EventID: [23, 26]}
+  - match: {product: windows, category: clipboard_capture}
+    rewrite: {service: sysmon}
+    conditions: {EventID: 24}
+  - match: {product: windows, category: process_tampering}
+    rewrite: {service: sysmon}
+    conditions: {EventID: 25}
+`
+
+// Native windows auditing, fields differ per event type so they are mapped per logsource
+const pipelineWindowsSecurity = `
+name: windows-security
+version: "1"
+fieldmappings:
+  User: SubjectUserName
+  LogonId: SubjectLogonId
+logsources:
+  - match: {product: windows, category: process_creation}
+    rewrite: {service: security}
+    conditions: {EventID: 4688}
+    fieldmappings:
+      Image: NewProcessName
+      ParentImage: ParentProcessName
+      ProcessId: NewProcessId
+      ParentProcessId: ProcessId
+      IntegrityLevel: MandatoryLabel
+  - match: {product: windows, category: network_connection}
+    rewrite: {service: security}
+    conditions: {EventID: 5156}
+    fieldmappings:
+      Image: Application
+      ProcessId: ProcessID
+      SourceIp: SourceAddress
+      DestinationIp: DestAddress
+      DestinationPort: DestPort
+  - match: {product: windows, category: file_event}
+    rewrite: {service: security}
+    conditions: {EventID: 4663}
+    fieldmappings:
+      Image: ProcessName
+      TargetFilename: ObjectName
+  - match: {product: windows, category: registry_set}
+    rewrite: {service: security}
+    conditions: {EventID: 4657}
+    fieldmappings:
+      Image: ProcessName
+      TargetObject: ObjectName
+      Details: NewValue
+  - match: {product: windows, category: registry_event}
+    rewrite: {service: security}
+    conditions: {EventID: [4657, 4663]}
+    fieldmappings:
+      Image: ProcessName
+      TargetObject: ObjectName
+      Details: NewValue
+`
+
+// Elastic Common Schema as produced by winlogbeat and elastic endpoint
+const pipelineECS = `
+name: ecs
+version: "8.11"
+fieldmappings:
+  EventID: event.code
+  Channel: winlog.channel
+  Provider_Name: winlog.provider_name
+  Computer: host.name
+  User: user.name
+  LogonId: winlog.logon.id
+  CommandLine: process.command_line
+  Image: process.executable
+  OriginalFileName: process.pe.original_file_name
+  Company: process.pe.company
+  Description: process.pe.description
+  Product: process.pe.product
+  FileVersion: process.pe.file_version
+  ProcessId: process.pid
+  ProcessGuid: process.entity_id
+  CurrentDirectory: process.working_directory
+  IntegrityLevel: winlog.event_data.IntegrityLevel
+  ParentImage: process.parent.executable
+  ParentCommandLine: process.parent.command_line
+  ParentProcessId: process.parent.pid
+  ParentProcessGuid: process.parent.entity_id
+  TargetFilename: file.path
+  ImageLoaded: dll.path
+  TargetObject: registry.path
+  Details: registry.data.strings
+  DestinationIp: destination.ip
+  DestinationPort: destination.port
+  DestinationHostname: destination.domain
+  SourceIp: source.ip
+  SourcePort: source.port
+  Protocol: network.transport
+  QueryName: dns.question.name
+  PipeName: file.name
+logsources:
+  - match: {category: process_creation}
+    conditions: {event.category: process, event.type: start}
+  - match: {category: process_termination}
+    conditions: {event.category: process, event.type: end}
+  - match: {category: network_connection}
+    conditions: {event.category: network}
+  - match: {category: dns_query}
+    conditions: {event.category: network, dns.type: query}
+  - match: {category: file_event}
+    conditions: {event.category: file}
+  - match: {category: file_delete}
+    conditions: {event.category: file, event.type: deletion}
+  - match: {category: image_load}
+    conditions: {event.category: library}
+  - match: {category: registry_event}
+    conditions: {event.category: registry}
+  - match: {category: registry_add}
+    conditions: {event.category: registry}
+  - match: {category: registry_set}
+    conditions: {event.category: registry}
+  - match: {category: registry_delete}
+    conditions: {event.category: registry}
+`
+
+// Open Cybersecurity Schema Framework, conditions select event class and activity
+const pipelineOCSF = `
+name: ocsf
+version: "1.1.0"
+fieldmappings:
+  EventID: metadata.event_code
+  Computer: device.hostname
+  User: actor.user.name
+  CommandLine: process.cmd_line
+  Image: process.file.path
+  ProcessId: process.pid
+  ProcessGuid: process.uid
+  IntegrityLevel: process.integrity
+  ParentImage: process.parent_process.file.path
+  ParentCommandLine: process.parent_process.cmd_line
+  ParentProcessId: process.parent_process.pid
+  ParentProcessGuid: process.parent_process.uid
+  TargetFilename: file.path
+  ImageLoaded: module.file.path
+  TargetObject: reg_key.path
+  Details: reg_value.data
+  DestinationIp: dst_endpoint.ip
+  DestinationPort: dst_endpoint.port
+  DestinationHostname: dst_endpoint.hostname
+  SourceIp: src_endpoint.ip
+  SourcePort: src_endpoint.port
+  Protocol: connection_info.protocol_name
+  QueryName: query.hostname
+logsources:
+  - match: {category: process_creation}
+    conditions: {class_uid: 1007, activity_id: 1}
+  - match: {category: process_termination}
+    conditions: {class_uid: 1007, activity_id: 2}
+  - match: {category: process_access}
+    conditions: {class_uid: 1007, activity_id: 3}
+  - match: {category: file_event}
+    conditions: {class_uid: 1001}
+  - match: {category: file_delete}
+    conditions: {class_uid: 1001, activity_id: 4}
+  - match: {category: image_load}
+    conditions: {class_uid: 1005, activity_id: 1}
+  - match: {category: network_connection}
+    conditions: {class_uid: 4001}
+  - match: {category: dns_query}
+    conditions: {class_uid: 4003, activity_id: 1}
+  - match: {category: registry_event}
+    conditions: {class_uid: [201001, 201002]}
+  - match: {category: registry_add}
+    conditions: {class_uid: 201001, activity_id: 1}
+  - match: {category: registry_delete}
+    conditions: {class_uid: [201001, 201002], activity_id: 4}
+  - match: {category: registry_set}
+    conditions: {class_uid: 201002}
+`
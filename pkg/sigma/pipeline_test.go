@@ -0,0 +1,267 @@
+package sigma
+
+import (
+	"testing"
+)
+
+var testPipeline = `
+name: ecs-test
+fieldmappings:
+  CommandLine: process.command_line
+  Image: process.executable
+logsources:
+  - match:
+      product: windows
+      category: process_creation
+    rewrite:
+      product: endpoint
+      category: process
+    conditions:
+      event.type: start
+`
+
+func TestPipelineRuleset(t *testing.T) {
+	dirs, cleanup := newTestRuleDirs(t, []testRuleFile{
+		{name: "a.yml", title: "whoami", pattern: "whoami",
+			logsource: "    product: windows\n    category: process_creation"},
+	})
+	defer cleanup()
+
+	p, err := NewPipeline([]byte(testPipeline))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewRuleset(&Config{Directories: dirs, Pipelines: []*Pipeline{p}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ls := Logsource{Product: "endpoint", Category: "process"}
+	if _, ok := r.Check(dummyObject{
+		"process.command_line": "whoami /all",
+		"event.type":           "start",
+	}, ls, false); !ok {
+		t.Fatal("translated rule did not match translated event")
+	}
+	if _, ok := r.Check(dummyObject{
+		"process.command_line": "whoami /all",
+		"event.type":           "end",
+	}, ls, false); ok {
+		t.Fatal("rule matched event that does not satisfy pipeline condition")
+	}
+	if _, ok := r.Check(dummyObject{
+		"CommandLine": "whoami /all",
+		"event.type":  "start",
+	}, ls, false); ok {
+		t.Fatal("rule matched untranslated field name")
+	}
+	if _, ok := r.Check(dummyObject{
+		"process.command_line": "whoami /all",
+		"event.type":           "start",
+	}, Logsource{Product: "windows", Category: "process_creation"}, false); ok {
+		t.Fatal("rule logsource was not rewritten")
+	}
+}
+
+func TestPipelineDropUnmapped(t *testing.T) {
+	dirs, cleanup := newTestRuleDirs(t, []testRuleFile{
+		{name: "a.yml", title: "whoami", pattern: "whoami"},
+	})
+	defer cleanup()
+
+	p := &Pipeline{
+		Name:          "strict",
+		FieldMappings: map[string]string{"Image": "process.executable"},
+		DropUnmapped:  true,
+	}
+	r, _ := NewRuleset(&Config{Directories: dirs, Pipelines: []*Pipeline{p}})
+	if r.Total != 0 || len(r.Unsupported) != 1 {
+		t.Fatalf("expected rule to be unsupported, got %d rules and %d unsupported", r.Total, len(r.Unsupported))
+	}
+	if _, ok := r.Unsupported[0].Error.(ErrUnmappedFields); !ok {
+		t.Fatalf("expected ErrUnmappedFields, got %+v", r.Unsupported[0].Error)
+	}
+}
+
+func TestPipelineRenameFields(t *testing.T) {
+	p := Pipeline{FieldMappings: map[string]string{
+		"Image":       "process.executable",
+		"CommandLine": "process.command_line",
+	}}
+	raw, _, err := p.Apply(RawRule{Detection: Detection{
+		"condition": "selection or keywords",
+		"selection": []interface{}{
+			map[interface{}]interface{}{"Image|endswith": `\cmd.exe`},
+			map[interface{}]interface{}{"CommandLine|contains": "whoami"},
+		},
+		"keywords": []interface{}{"Image"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sel := raw.Detection["selection"].([]interface{})
+	if _, ok := sel[0].(map[interface{}]interface{})["process.executable|endswith"]; !ok {
+		t.Fatalf("field not renamed: %+v", sel[0])
+	}
+	if _, ok := sel[1].(map[interface{}]interface{})["process.command_line|contains"]; !ok {
+		t.Fatalf("field not renamed: %+v", sel[1])
+	}
+	if kw := raw.Detection["keywords"].([]interface{}); kw[0] != "Image" {
+		t.Fatalf("keywords should not be renamed: %+v", kw)
+	}
+}
+
+func TestBundledPipelines(t *testing.T) {
+	for _, name := range BundledPipelines() {
+		p, err := BundledPipeline(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Name != name || p.Version == "" {
+			t.Fatalf("bundled pipeline %s has name %s and version %s", name, p.Name, p.Version)
+		}
+		if _, err := BundledPipeline(name + "@" + p.Version); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := BundledPipeline("ecs@0.1"); err == nil {
+		t.Fatal("version mismatch should return error")
+	}
+	if _, err := BundledPipeline("splunk"); err == nil {
+		t.Fatal("unknown pipeline should return error")
+	}
+}
+
+func TestBundledPipelineMapping(t *testing.T) {
+	dirs, cleanup := newTestRuleDirs(t, []testRuleFile{
+		{name: "a.yml", title: "whoami", pattern: "whoami",
+			logsource: "    product: windows\n    category: process_creation"},
+	})
+	defer cleanup()
+
+	for _, c := range []struct {
+		mapping string
+		ls      Logsource
+		event   dummyObject
+	}{
+		{
+			mapping: "ecs",
+			ls:      Logsource{Product: "windows", Category: "process_creation"},
+			event: dummyObject{
+				"process.command_line": "whoami",
+				"event.category":       "process",
+				"event.type":           "start",
+			},
+		},
+		{
+			mapping: "ocsf",
+			ls:      Logsource{Product: "windows", Category: "process_creation"},
+			event: dummyObject{
+				"process.cmd_line": "whoami",
+				"class_uid":        1007,
+				"activity_id":      1,
+			},
+		},
+		{
+			mapping: "sysmon",
+			ls:      Logsource{Product: "windows", Category: "process_creation", Service: "sysmon"},
+			event:   dummyObject{"CommandLine": "whoami", "EventID": 1},
+		},
+		{
+			mapping: "windows-security",
+			ls:      Logsource{Product: "windows", Category: "process_creation", Service: "security"},
+			event:   dummyObject{"CommandLine": "whoami", "EventID": 4688},
+		},
+	} {
+		r, err := NewRuleset(&Config{Directories: dirs, Mappings: []string{c.mapping}})
+		if err != nil {
+			t.Fatalf("%s: %s", c.mapping, err)
+		}
+		if _, ok := r.Check(c.event, c.ls, true); !ok {
+			t.Fatalf("%s: rule did not match %+v", c.mapping, c.event)
+		}
+	}
+}
+
+func TestBundledPipelineLogsourceMapping(t *testing.T) {
+	for _, c := range []struct {
+		mapping  string
+		rule     testRuleFile
+		ls       Logsource
+		positive dummyObject
+		// events that only fail on logsource scoped mapping
+		negative []dummyObject
+	}{
+		{
+			mapping: "sysmon",
+			rule: testRuleFile{name: "a.yml", title: "dll", pattern: "evil.dll", field: "ImageLoaded",
+				logsource: "    product: windows\n    category: image_load"},
+			ls:       Logsource{Product: "windows", Category: "image_load", Service: "sysmon"},
+			positive: dummyObject{"ImageLoaded": "evil.dll", "EventID": 7},
+			negative: []dummyObject{{"ImageLoaded": "evil.dll", "EventID": 1}},
+		},
+		{
+			mapping: "windows-security",
+			rule: testRuleFile{name: "a.yml", title: "connection", pattern: `C:\evil.exe`, field: "Image",
+				logsource: "    product: windows\n    category: network_connection"},
+			ls:       Logsource{Product: "windows", Category: "network_connection", Service: "security"},
+			positive: dummyObject{"Application": `C:\evil.exe`, "EventID": 5156},
+			negative: []dummyObject{
+				{"Image": `C:\evil.exe`, "EventID": 5156},
+				{"NewProcessName": `C:\evil.exe`, "EventID": 5156},
+				{"Application": `C:\evil.exe`, "EventID": 4688},
+			},
+		},
+		{
+			mapping: "ecs",
+			rule: testRuleFile{name: "a.yml", title: "dns", pattern: "evil.com", field: "QueryName",
+				logsource: "    product: windows\n    category: dns_query"},
+			ls:       Logsource{Product: "windows", Category: "dns_query"},
+			positive: dummyObject{"dns.question.name": "evil.com", "event.category": "network", "dns.type": "query"},
+			negative: []dummyObject{{"dns.question.name": "evil.com", "event.category": "network", "dns.type": "answer"}},
+		},
+		{
+			mapping: "ocsf",
+			rule: testRuleFile{name: "a.yml", title: "registry", pattern: `HKLM\Run`, field: "TargetObject",
+				logsource: "    product: windows\n    category: registry_set"},
+			ls:       Logsource{Product: "windows", Category: "registry_set"},
+			positive: dummyObject{"reg_key.path": `HKLM\Run`, "class_uid": 201002},
+			negative: []dummyObject{{"reg_key.path": `HKLM\Run`, "class_uid": 201001}},
+		},
+	} {
+		dirs, cleanup := newTestRuleDirs(t, []testRuleFile{c.rule})
+		r, err := NewRuleset(&Config{Directories: dirs, Mappings: []string{c.mapping}})
+		cleanup()
+		if err != nil {
+			t.Fatalf("%s: %s", c.mapping, err)
+		}
+		if _, ok := r.Check(c.positive, c.ls, true); !ok {
+			t.Fatalf("%s: rule did not match %+v", c.mapping, c.positive)
+		}
+		for _, event := range c.negative {
+			if _, ok := r.Check(event, c.ls, true); ok {
+				t.Fatalf("%s: rule should not match %+v", c.mapping, event)
+			}
+		}
+	}
+}
+
+func TestPipelineConditionsCoerceNumbers(t *testing.T) {
+	dirs, cleanup := newTestRuleDirs(t, []testRuleFile{
+		{name: "a.yml", title: "whoami", pattern: "whoami",
+			logsource: "    product: windows\n    category: process_creation"},
+	})
+	defer cleanup()
+
+	r, err := NewRuleset(&Config{Directories: dirs, Mappings: []string{"sysmon"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ls := Logsource{Product: "windows", Category: "process_creation", Service: "sysmon"}
+	// numeric event fields are often decoded as strings, conditions should match like rule selections
+	if _, ok := r.Check(dummyObject{"CommandLine": "whoami", "EventID": "1"}, ls, true); !ok {
+		t.Fatal("pipeline condition EventID: 1 should match string EventID")
+	}
+	if _, ok := r.Check(dummyObject{"CommandLine": "whoami", "EventID": "7"}, ls, true); ok {
+		t.Fatal("pipeline condition EventID: 1 should not match string EventID 7")
+	}
+}
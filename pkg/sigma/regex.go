@@ -0,0 +1,184 @@
+package sigma
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+)
+
+// RegexLimits bound complexity of regular expressions in a single rule
+// Zero values are replaced with DefaultRegexLimits, negative values disable the check
+type RegexLimits struct {
+	// MaxLength is maximum length of a single pattern
+	MaxLength int
+	// MaxProgram is maximum number of compiled instructions of all patterns in a rule
+	MaxProgram int
+}
+
+// DefaultRegexLimits are generous enough for any regex in public sigma rules
+var DefaultRegexLimits = RegexLimits{MaxLength: 2048, MaxProgram: 20000}
+
+func (l RegexLimits) withDefaults() RegexLimits {
+	if l.MaxLength == 0 {
+		l.MaxLength = DefaultRegexLimits.MaxLength
+	}
+	if l.MaxProgram == 0 {
+		l.MaxProgram = DefaultRegexLimits.MaxProgram
+	}
+	return l
+}
+
+// ErrRegexLimit is returned for rules with regular expressions that exceed RegexLimits
+type ErrRegexLimit struct {
+	Pattern string
+	Reason  string
+}
+
+func (e ErrRegexLimit) Error() string {
+	pattern := e.Pattern
+	if len(pattern) > 64 {
+		pattern = pattern[:64] + "..."
+	}
+	return fmt.Sprintf("regex /%s/ rejected, %s", pattern, e.Reason)
+}
+
+// regexBudget enforces RegexLimits while regular expressions of a single rule are compiled
+// Patterns are compiled without limits if budget is nil
+type regexBudget struct {
+	limits RegexLimits
+	// instructions of patterns compiled so far
+	program int
+}
+
+func newRegexBudget(limits RegexLimits) *regexBudget {
+	return &regexBudget{limits: limits.withDefaults()}
+}
+
+func (b *regexBudget) checkLength(pattern string) error {
+	if b == nil || b.limits.MaxLength <= 0 || len(pattern) <= b.limits.MaxLength {
+		return nil
+	}
+	return ErrRegexLimit{
+		Pattern: pattern,
+		Reason:  fmt.Sprintf("length %d exceeds limit %d", len(pattern), b.limits.MaxLength),
+	}
+}
+
+func (b *regexBudget) add(pattern string, program int) error {
+	if b == nil {
+		return nil
+	}
+	b.program += program
+	if b.limits.MaxProgram <= 0 || b.program <= b.limits.MaxProgram {
+		return nil
+	}
+	return ErrRegexLimit{
+		Pattern: pattern,
+		Reason:  fmt.Sprintf("rule program size %d exceeds limit %d", b.program, b.limits.MaxProgram),
+	}
+}
+
+// regexMatcher gates regex evaluation with a substring every match must contain
+type regexMatcher struct {
+	*regexp.Regexp
+
+	// required is empty if no literal could be extracted
+	required string
+	// fold is set for case insensitive literals, required is lowercase then
+	fold bool
+}
+
+// newRegexMatcher compiles pattern if it fits the budget
+// Length is checked before parsing and program size before the pattern is compiled into a Regexp
+func newRegexMatcher(pattern string, budget *regexBudget) (regexMatcher, error) {
+	if err := budget.checkLength(pattern); err != nil {
+		return regexMatcher{}, err
+	}
+	parsed, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return regexMatcher{}, err
+	}
+	parsed = parsed.Simplify()
+	prog, err := syntax.Compile(parsed)
+	if err != nil {
+		return regexMatcher{}, err
+	}
+	if err := budget.add(pattern, len(prog.Inst)); err != nil {
+		return regexMatcher{}, err
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return regexMatcher{}, err
+	}
+	lit := requiredLiteral(parsed)
+	return regexMatcher{
+		Regexp:   re,
+		required: lit.s,
+		fold:     lit.fold,
+	}, nil
+}
+
+// match reports whether s contains a match of the regex
+func (m regexMatcher) match(s string) bool {
+	if m.required != "" {
+		if !m.fold {
+			if !strings.Contains(s, m.required) {
+				return false
+			}
+		} else if isASCII(s) && index(s, m.required, true) < 0 {
+			// regex case folding also maps some non-ASCII runes to ASCII letters, so only ASCII input is gated
+			return false
+		}
+	}
+	return m.MatchString(s)
+}
+
+type literal struct {
+	s    string
+	fold bool
+}
+
+// requiredLiteral returns longest literal that occurs in every match of the regex
+// Case insensitive literals are returned in lowercase
+func requiredLiteral(re *syntax.Regexp) literal {
+	switch re.Op {
+	case syntax.OpLiteral:
+		if re.Flags&syntax.FoldCase != 0 {
+			return literal{s: strings.ToLower(string(re.Rune)), fold: true}
+		}
+		return literal{s: string(re.Rune)}
+	case syntax.OpCapture, syntax.OpPlus:
+		return requiredLiteral(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Min > 0 {
+			return requiredLiteral(re.Sub[0])
+		}
+	case syntax.OpConcat:
+		var best, run literal
+		longer := func(l literal) {
+			if len(l.s) > len(best.s) {
+				best = l
+			}
+		}
+		for _, sub := range re.Sub {
+			if sub.Op == syntax.OpLiteral {
+				l := requiredLiteral(sub)
+				// adjacent literals join into a longer one, unless case sensitivity differs
+				if run.s != "" && run.fold == l.fold {
+					run.s += l.s
+				} else {
+					longer(run)
+					run = l
+				}
+				continue
+			}
+			longer(run)
+			run = literal{}
+			longer(requiredLiteral(sub))
+		}
+		longer(run)
+		return best
+	}
+	return literal{}
+}
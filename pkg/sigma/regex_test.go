@@ -0,0 +1,142 @@
+package sigma
+
+import (
+	"math/rand"
+	"path/filepath"
+	"regexp/syntax"
+	"strings"
+	"testing"
+)
+
+func TestRequiredLiteral(t *testing.T) {
+	for _, tc := range []struct {
+		pattern string
+		lit     literal
+	}{
+		{`foo`, literal{s: "foo"}},
+		{`^c:\\windows\\.*\\cmd\.exe$`, literal{s: `c:\windows\`}},
+		{`(?i)invoke-(mimikatz|expression)`, literal{s: "invoke-", fold: true}},
+		{`a+bcd\d+`, literal{s: "bcd"}},
+		{`(ab){2,}x`, literal{s: "ab"}},
+		{`(abc)?d`, literal{s: "d"}},
+		{`foo|bar`, literal{}},
+		{`x*`, literal{}},
+		{`Ab(?i:cde)`, literal{s: "cde", fold: true}},
+	} {
+		re, err := syntax.Parse(tc.pattern, syntax.Perl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lit := requiredLiteral(re.Simplify()); lit != tc.lit {
+			t.Fatalf("%s: expected %+v, got %+v", tc.pattern, tc.lit, lit)
+		}
+	}
+}
+
+func TestRegexMatcher(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, pattern := range []string{
+		`ab+c`, `(?i)ab+c`, `^a.*bc$`, `(ab|cd)ef`, `(?i)s+k`, `[ab]{2}cc`, `a(bc)+a`,
+	} {
+		m, err := newRegexMatcher(pattern, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 2000; i++ {
+			s := randomString(r, "abcdefABCsSkK\u017f\u212a", 12)
+			if m.match(s) != m.MatchString(s) {
+				t.Fatalf("%s against %q: expected %t", pattern, s, m.MatchString(s))
+			}
+		}
+	}
+}
+
+func TestRegexBudget(t *testing.T) {
+	// length is checked before parsing, so an oversized invalid pattern is rejected by limit
+	budget := newRegexBudget(RegexLimits{MaxLength: 16})
+	if _, err := newRegexMatcher("("+strings.Repeat("a", 32), budget); err == nil {
+		t.Fatal("long pattern should be rejected")
+	} else if _, ok := err.(ErrRegexLimit); !ok {
+		t.Fatalf("expected ErrRegexLimit, got %v", err)
+	}
+	// program size accumulates over patterns of a rule and is checked before Regexp is built
+	budget = newRegexBudget(RegexLimits{MaxProgram: 20})
+	m, err := newRegexMatcher("abc", budget)
+	if err != nil || m.Regexp == nil {
+		t.Fatalf("first pattern should fit, got %v", err)
+	}
+	m, err = newRegexMatcher("abcdefghijklmnop", budget)
+	if _, ok := err.(ErrRegexLimit); !ok || m.Regexp != nil {
+		t.Fatalf("second pattern should exceed program limit without compiling, got %+v %v", m, err)
+	}
+	if _, err := ParseDetectionWithLimits(Detection{
+		"selection": map[string]interface{}{"CommandLine": "/" + strings.Repeat("a", 32) + "/"},
+		"condition": "selection",
+	}, RegexLimits{MaxLength: 16}); err == nil {
+		t.Fatal("detection should be rejected while parsing")
+	}
+}
+
+func TestRulesetRegexLimits(t *testing.T) {
+	huge := "/(" + strings.Repeat("a", 3000) + ")/"
+	dirs, cleanup := newTestRuleDirs(t, []testRuleFile{
+		{name: "ok.yml", id: testID1, title: "ok", pattern: `/whoami\s+\/all/`},
+		{name: "long.yml", id: testID2, title: "long", pattern: huge},
+	})
+	defer cleanup()
+
+	r, err := NewRuleset(&Config{Directories: dirs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Total != 1 || len(r.Broken) != 1 {
+		t.Fatalf("expected 1 rule and 1 broken, got %d and %d", r.Total, len(r.Broken))
+	}
+	if e, ok := r.Broken[0].Error.(ErrRegexLimit); !ok || !strings.Contains(e.Reason, "length 3002 exceeds limit") {
+		t.Fatalf("wrong error %v", r.Broken[0].Error)
+	}
+	// both exceed the program limit
+	r, err = NewRuleset(&Config{Directories: dirs, RegexLimits: RegexLimits{MaxLength: -1, MaxProgram: 10}})
+	if err == nil || len(r.Broken) != 2 {
+		t.Fatalf("expected 2 broken rules, got %d and error %v", len(r.Broken), err)
+	}
+	r, err = NewRuleset(&Config{Directories: dirs, RegexLimits: RegexLimits{MaxLength: -1, MaxProgram: -1}})
+	if err != nil || r.Total != 2 {
+		t.Fatalf("expected 2 rules without limits, got %d and error %v", r.Total, err)
+	}
+
+	// lint reports rules that NewRuleset marks as broken
+	res, err := Lint(&Config{Directories: dirs})
+	if err != nil || len(res) != 1 || res[0].Path != filepath.Join(dirs[0], "long.yml") {
+		t.Fatalf("expected lint violation for long regex, got %+v and error %v", res, err)
+	}
+	if res, err := Lint(&Config{Directories: dirs, RegexLimits: RegexLimits{MaxLength: -1, MaxProgram: -1}}); err != nil || len(res) != 0 {
+		t.Fatalf("expected no lint violations without limits, got %+v and error %v", res, err)
+	}
+
+	long := filepath.Join(dirs[0], "long.yml")
+	if _, err := LoadRule(long, RegexLimits{}); err == nil {
+		t.Fatal("single rule should be checked against default limits")
+	}
+	if _, err := LoadRule(long, RegexLimits{MaxLength: -1, MaxProgram: -1}); err != nil {
+		t.Fatalf("single rule should be loaded without limits, got %s", err)
+	}
+}
+
+func BenchmarkRegexMatcher(b *testing.B) {
+	m, err := newRegexMatcher(`(?i)invoke-(mimikatz|expression|webrequest)`, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	subject := `"C:\Windows\System32\WindowsPowerShell\v1.0\powershell.exe" -NoProfile -ExecutionPolicy Bypass -File C:\scripts\backup.ps1`
+	b.Run("plain", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			m.MatchString(subject)
+		}
+	})
+	b.Run("gated", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			m.match(subject)
+		}
+	})
+}
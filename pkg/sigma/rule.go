@@ -3,31 +3,35 @@ package sigma
 import (
 	"fmt"
 	"reflect"
-	"regexp"
+	"sort"
 	"strconv"
 	"strings"
-	"time"
-
-	"github.com/ryanuber/go-glob"
+	"sync/atomic"
 )
 
 type stringPatterns struct {
 	literals []string
-	re       []*regexp.Regexp
-	globs    []string
+	re       []regexMatcher
+	globs    []globMatcher
+
+	// literals indexed by prefilter, see NewPrefilter
+	prefilter *literalRef
 }
 
-func newStringPatterns(patterns ...string) (*stringPatterns, error) {
+func newStringPatterns(budget *regexBudget, patterns ...string) (*stringPatterns, error) {
 	k := &stringPatterns{}
 	for _, p := range patterns {
 		p = strings.TrimSpace(p)
 		if strings.HasPrefix(p, "/") && strings.HasSuffix(p, "/") {
 			if k.re == nil {
-				k.re = make([]*regexp.Regexp, 0)
+				k.re = make([]regexMatcher, 0)
 			}
 			p = strings.TrimLeft(p, "/")
 			p = strings.TrimRight(p, "/")
-			re, err := regexp.Compile(p)
+			re, err := newRegexMatcher(p, budget)
+			if _, ok := err.(ErrRegexLimit); ok {
+				return k, err
+			}
 			if err != nil {
 				return k, ErrInvalidRegex{
 					Pattern: p,
@@ -37,9 +41,9 @@ func newStringPatterns(patterns ...string) (*stringPatterns, error) {
 			k.re = append(k.re, re)
 		} else if strings.Contains(p, "*") {
 			if k.globs == nil {
-				k.globs = make([]string, 0)
+				k.globs = make([]globMatcher, 0)
 			}
-			k.globs = append(k.globs, p)
+			k.globs = append(k.globs, newGlobMatcher(p))
 		} else {
 			if k.literals == nil {
 				k.literals = make([]string, 0)
@@ -51,15 +55,21 @@ func newStringPatterns(patterns ...string) (*stringPatterns, error) {
 }
 
 type Keyword struct {
+	// first field for 64 bit alignment of atomic counters
+	Stats
 	stringPatterns
 	toLower bool
-	Stats
+	ident   string
 }
 
 func NewKeywordFromInterface(lowercase bool, expr interface{}) (*Keyword, error) {
+	return newKeywordFromInterface(lowercase, expr, nil)
+}
+
+func newKeywordFromInterface(lowercase bool, expr interface{}, budget *regexBudget) (*Keyword, error) {
 	switch v := expr.(type) {
 	case []string:
-		return NewKeyword(lowercase, v...)
+		return newKeyword(lowercase, budget, v...)
 	case []interface{}:
 		slc := make([]string, 0)
 		for _, item := range v {
@@ -72,10 +82,10 @@ func NewKeywordFromInterface(lowercase bool, expr interface{}) (*Keyword, error)
 				slc = append(slc, strconv.Itoa(int(cast)))
 			}
 		}
-		return NewKeyword(lowercase, slc...)
+		return newKeyword(lowercase, budget, slc...)
 	case map[string]interface{}:
 		if patterns, ok := v["Message"].([]string); ok {
-			return NewKeyword(lowercase, patterns...)
+			return newKeyword(lowercase, budget, patterns...)
 		}
 	case map[interface{}]interface{}:
 		if vals, ok := v["Message"]; ok {
@@ -92,7 +102,7 @@ func NewKeywordFromInterface(lowercase bool, expr interface{}) (*Keyword, error)
 						slc = append(slc, strconv.Itoa(int(cast)))
 					}
 				}
-				return NewKeyword(lowercase, slc...)
+				return newKeyword(lowercase, budget, slc...)
 			}
 		}
 	}
@@ -102,19 +112,22 @@ func NewKeywordFromInterface(lowercase bool, expr interface{}) (*Keyword, error)
 }
 
 func NewKeyword(lowercase bool, patterns ...string) (*Keyword, error) {
+	return newKeyword(lowercase, nil, patterns...)
+}
+
+func newKeyword(lowercase bool, budget *regexBudget, patterns ...string) (*Keyword, error) {
 	if patterns == nil || len(patterns) == 0 {
 		return nil, fmt.Errorf("no patterns defined for keyword match rule")
 	}
 	k := &Keyword{
 		toLower: lowercase,
-		Stats:   Stats{},
 	}
 	for i, pat := range patterns {
 		if lowercase {
 			patterns[i] = strings.ToLower(pat)
 		}
 	}
-	p, err := newStringPatterns(patterns...)
+	p, err := newStringPatterns(budget, patterns...)
 	if err != nil {
 		return k, err
 	}
@@ -123,37 +136,58 @@ func NewKeyword(lowercase bool, patterns ...string) (*Keyword, error) {
 }
 
 func (k *Keyword) Match(obj EventChecker) bool {
-	k.Total++
+	return k.record(k.match(obj))
+}
+
+func (k *Keyword) match(obj EventChecker) bool {
+	if e, ok := k.prefiltered(obj); ok {
+		if k.prefilter.any(e.messageHits(k.prefilter.automaton)) {
+			return true
+		}
+		p := k.stringPatterns
+		p.literals = nil
+		return matchKeywords(p, k.toLower, obj.GetMessage()...)
+	}
 	return matchKeywords(k.stringPatterns, k.toLower, obj.GetMessage()...)
 }
 
+// Self returns a copy of the keyword, use Snapshot to read its counters
 func (k Keyword) Self() interface{} { return k }
 
+// Ident returns name of detection identifier the keywords were defined in
+func (k Keyword) Ident() string { return k.ident }
+
 func matchKeywords(k stringPatterns, lowercase bool, fields ...string) bool {
 	if fields == nil || len(fields) == 0 {
 		return false
 	}
 	for _, field := range fields {
-		if lowercase {
-			field = strings.ToLower(field)
+		// ASCII values are compared case insensitively without making a lowercase copy
+		fold := lowercase
+		if lowercase && !isASCII(field) {
+			field, fold = strings.ToLower(field), false
 		}
 		if k.literals != nil && len(k.literals) > 0 {
 			for _, pattern := range k.literals {
-				if strings.Contains(field, pattern) {
+				if index(field, pattern, fold) >= 0 {
 					return true
 				}
 			}
 		}
 		if k.re != nil && len(k.re) > 0 {
+			lowered := field
+			if fold {
+				lowered = strings.ToLower(field)
+			}
 			for _, re := range k.re {
-				if re.MatchString(field) {
+				if re.match(lowered) {
 					return true
 				}
 			}
 		}
 		if k.globs != nil && len(k.globs) > 0 {
 			for _, g := range k.globs {
-				if glob.Glob(g, field) {
+				if g.match(field, fold) {
 					return true
 				}
 			}
@@ -165,6 +199,9 @@ func matchKeywords(k stringPatterns, lowercase bool, fields ...string) bool {
 type RuleConfig struct {
 	LowerCase   bool
 	NumToString bool
+
+	// limits regular expressions of the rule being parsed
+	regex *regexBudget
 }
 
 type FieldsList []*Fields
@@ -186,6 +223,14 @@ func (f FieldsList) Self() interface{} {
 	return f
 }
 
+// Ident returns name of detection identifier the selection list was defined in
+func (f FieldsList) Ident() string {
+	if len(f) == 0 {
+		return ""
+	}
+	return f[0].ident
+}
+
 // JSON numbers are by spec all float64 values
 type numPatterns []float64
 
@@ -198,47 +243,106 @@ func (p numPatterns) match(n float64) bool {
 	return false
 }
 
+// matchNumber checks numeric event values, lists match if any element matches
+// Strings are parsed as decimal numbers if tryString is set
 func (p numPatterns) matchNumber(num interface{}, tryString bool) bool {
 	switch nu := num.(type) {
-	case float64:
-		return p.match(nu)
-	case int:
-		return p.match(float64(nu))
-	case int64:
-		return p.match(float64(nu))
-	case uint:
-		return p.match(float64(nu))
-	case uint64:
-		return p.match(float64(nu))
-	case int32:
-		return p.match(float64(nu))
-	case uint32:
-		return p.match(float64(nu))
 	case string:
 		if tryString {
-			if val, err := strconv.Atoi(nu); err == nil {
-				return p.match(float64(val))
+			if val, err := strconv.ParseFloat(strings.TrimSpace(nu), 64); err == nil {
+				return p.match(val)
+			}
+		}
+		return false
+	case []interface{}:
+		for _, item := range nu {
+			if p.matchNumber(item, tryString) {
+				return true
 			}
 		}
 		return false
-	default:
+	case []string:
+		for _, item := range nu {
+			if p.matchNumber(item, tryString) {
+				return true
+			}
+		}
 		return false
 	}
+	if val, ok := toFloat(num); ok {
+		return p.match(val)
+	}
+	return false
 }
 
 func (p numPatterns) len() int    { return len(p) }
 func (p numPatterns) empty() bool { return p.len() == 0 }
 
+type boolPatterns []bool
+
+// matchBool checks boolean event values, lists match if any element matches
+// Strings true and false are accepted in any case if tryString is set
+func (p boolPatterns) matchBool(val interface{}, tryString bool) bool {
+	switch v := val.(type) {
+	case bool:
+		for _, b := range p {
+			if b == v {
+				return true
+			}
+		}
+	case string:
+		if tryString {
+			switch {
+			case strings.EqualFold(v, "true"):
+				return p.matchBool(true, false)
+			case strings.EqualFold(v, "false"):
+				return p.matchBool(false, false)
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if p.matchBool(item, tryString) {
+				return true
+			}
+		}
+	case []string:
+		for _, item := range v {
+			if p.matchBool(item, tryString) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Fields is a selection where all fields must match
+//
+// Event values returned by GetField are compared by type
+//   - missing field never matches, except for null pattern which matches missing or nil values
+//   - lists match if any element matches the pattern
+//   - numbers and booleans are formatted as strings for string patterns
+//   - strings are parsed as numbers and booleans for numeric and boolean patterns if string numbers are enabled
 type Fields struct {
+	// first field for 64 bit alignment of atomic counters
+	Stats
+
 	sPatterns map[string]stringPatterns
 	nPatterns map[string]numPatterns
+	bPatterns map[string]boolPatterns
+	nulls     []string
+	// keys of sPatterns from cheapest to most expensive
+	sOrder []string
 
 	toLower         bool
 	tryStingNumbers bool
-	Stats
+	ident           string
 }
 
 func NewFields(raw map[string]interface{}, lowercase, stringnum bool) (*Fields, error) {
+	return newFields(raw, lowercase, stringnum, nil)
+}
+
+func newFields(raw map[string]interface{}, lowercase, stringnum bool, budget *regexBudget) (*Fields, error) {
 	if raw == nil || len(raw) == 0 {
 		return nil, fmt.Errorf("wrong interface type for rule condition, only map[string]interface{} supported")
 	}
@@ -256,171 +360,242 @@ func NewFields(raw map[string]interface{}, lowercase, stringnum bool) (*Fields,
 		}
 		switch condition := v.(type) {
 		case nil:
-			// TODO
-			// Boolean pattern is a thing
-			// should handle in case json parser picks null value from rule
-		case string:
-			if f.sPatterns == nil {
-				f.sPatterns = make(map[string]stringPatterns)
-			}
-			patterns, err := newStringPatterns(condition)
-			if err != nil {
-				return f, err
-			}
-			f.sPatterns[k] = *patterns
-		case float64:
-			if f.nPatterns == nil {
-				f.nPatterns = make(map[string]numPatterns)
-			}
-			f.nPatterns[k] = []float64{condition}
-		case int:
-			if f.nPatterns == nil {
-				f.nPatterns = make(map[string]numPatterns)
-			}
-			f.nPatterns[k] = []float64{float64(condition)}
+			f.nulls = append(f.nulls, k)
 		case []string:
-			if f.sPatterns == nil {
-				f.sPatterns = make(map[string]stringPatterns)
-			}
-			patterns, err := newStringPatterns(condition...)
-			if err != nil {
+			if err := f.addStrings(budget, k, condition...); err != nil {
 				return f, err
 			}
-			f.sPatterns[k] = *patterns
 		case []interface{}:
-			var t reflect.Kind
-			var stringAndNumber bool
-			// make sure all list types are the same
-		loop:
-			for i, item := range condition {
-				if i > 0 {
-					if t2 := reflect.TypeOf(item).Kind(); t2 != t && (t == reflect.String || t2 == reflect.String) {
-						// Just convert all values to string if a single item happens to be one
-						stringAndNumber = true
-						t = reflect.String
-						break loop
-					} else if t2 != t {
-						return f, fmt.Errorf(
-							"Selection/field rule parse fail for key %s list contains %s and %s",
-							k,
-							t.String(),
-							t2.String(),
-						)
-					}
-				}
-				t = reflect.TypeOf(item).Kind()
+			if err := f.addList(budget, k, condition); err != nil {
+				return f, err
 			}
-			switch t {
-			case reflect.String:
-				if f.sPatterns == nil {
-					f.sPatterns = make(map[string]stringPatterns)
-				}
-				patterns, err := newStringPatterns(func() []string {
-					str := make([]string, len(condition))
-					for i, item := range condition {
-						// This should already be checked
-						if stringAndNumber {
-							switch cast := v.(type) {
-							case string:
-								str[i] = cast
-							case int:
-								str[i] = strconv.Itoa(cast)
-							case float64:
-								str[i] = strconv.Itoa(int(cast))
-							default:
-
-							}
-						} else {
-							str[i] = item.(string)
-						}
-					}
-					return str
-				}()...)
-				if err != nil {
-					return f, err
-				}
-				f.sPatterns[k] = *patterns
-			case reflect.Float64:
-				if f.nPatterns == nil {
-					f.nPatterns = make(map[string]numPatterns)
-				}
-				if f.nPatterns == nil {
-					f.nPatterns = make(map[string]numPatterns)
-				}
-				f.nPatterns[k] = func() []float64 {
-					flt := make([]float64, len(condition))
-					for i, item := range condition {
-						// this should already be checked
-						flt[i] = item.(float64)
-					}
-					return flt
-				}()
-			case reflect.Int:
-				if f.nPatterns == nil {
-					f.nPatterns = make(map[string]numPatterns)
-				}
-				if f.nPatterns == nil {
-					f.nPatterns = make(map[string]numPatterns)
+		default:
+			if err := f.addList(budget, k, []interface{}{condition}); err != nil {
+				if _, ok := err.(ErrRegexLimit); ok {
+					return nil, err
 				}
-				f.nPatterns[k] = func() []float64 {
-					flt := make([]float64, len(condition))
-					for i, item := range condition {
-						// this should already be checked
-						flt[i] = float64(item.(int))
-					}
-					return flt
-				}()
-			default:
-				return f, fmt.Errorf("unsupported type for key %s, got %s but expected string or float64", k, t.String())
+				return nil, fmt.Errorf(
+					"wrong rule type for [%+v], field [%s], got %T, only support string, number, bool, null, or their respective sliced versions",
+					raw, k, v,
+				)
 			}
-		default:
-			return nil, fmt.Errorf(
-				"wrong rule type for [%+v], field [%s], got %T, only support string, float64, or their respective sliced versions",
-				raw, k, v,
-			)
 		}
 	}
 	return f, nil
 }
 
+func (f *Fields) addStrings(budget *regexBudget, k string, patterns ...string) error {
+	if f.sPatterns == nil {
+		f.sPatterns = make(map[string]stringPatterns)
+	}
+	p, err := newStringPatterns(budget, patterns...)
+	if err != nil {
+		return err
+	}
+	if _, ok := f.sPatterns[k]; !ok {
+		f.sOrder = append(f.sOrder, k)
+	}
+	f.sPatterns[k] = *p
+	sort.SliceStable(f.sOrder, func(i, j int) bool {
+		return f.sPatterns[f.sOrder[i]].cost() < f.sPatterns[f.sOrder[j]].cost()
+	})
+	return nil
+}
+
+// addList sorts pattern values by type
+// Lists with both strings and other scalars are converted to string patterns
+func (f *Fields) addList(budget *regexBudget, k string, condition []interface{}) error {
+	var (
+		strs  bool
+		nums  = make(numPatterns, 0)
+		bools = make(boolPatterns, 0)
+	)
+	for _, item := range condition {
+		if item == nil {
+			return fmt.Errorf("selection/field rule parse fail for key %s, null can not be combined with other values", k)
+		}
+		if _, ok := item.(string); ok {
+			strs = true
+		} else if n, ok := toFloat(item); ok {
+			nums = append(nums, n)
+		} else if b, ok := item.(bool); ok {
+			bools = append(bools, b)
+		} else {
+			return fmt.Errorf("unsupported type for key %s, got %T but expected string, number or bool", k, item)
+		}
+	}
+	switch {
+	case strs || (len(nums) > 0 && len(bools) > 0):
+		// Just convert all values to string if a single item happens to be one
+		str := make([]string, len(condition))
+		for i, item := range condition {
+			str[i] = formatScalar(item)
+		}
+		return f.addStrings(budget, k, str...)
+	case len(nums) > 0:
+		if f.nPatterns == nil {
+			f.nPatterns = make(map[string]numPatterns)
+		}
+		f.nPatterns[k] = nums
+	case len(bools) > 0:
+		if f.bPatterns == nil {
+			f.bPatterns = make(map[string]boolPatterns)
+		}
+		f.bPatterns[k] = bools
+	}
+	return nil
+}
+
+// Match implements sigma Matcher
+// Scalar patterns are checked first and string patterns in order of their cost
 func (f *Fields) Match(obj EventChecker) bool {
-	f.Total++
-	if f.sPatterns != nil && len(f.sPatterns) > 0 {
-	and1:
-		for field, patterns := range f.sPatterns {
-			if val, ok := obj.GetField(field); ok {
-				if str, ok := val.(string); ok {
-					if matchKeywords(patterns, f.toLower, str) {
-						continue and1
-					}
-				}
+	return f.record(f.match(obj))
+}
+
+func (f *Fields) match(obj EventChecker) bool {
+	if f.nPatterns != nil && len(f.nPatterns) > 0 {
+		for field, patterns := range f.nPatterns {
+			val, ok := obj.GetField(field)
+			if !ok || !patterns.matchNumber(val, f.tryStingNumbers) {
 				return false
 			}
-			return false
 		}
 	}
-	if f.nPatterns != nil && len(f.nPatterns) > 0 {
-	and2:
-		for field, patterns := range f.nPatterns {
-			if val, ok := obj.GetField(field); ok {
-				if patterns.matchNumber(val, f.tryStingNumbers) {
-					continue and2
-				}
+	if f.bPatterns != nil && len(f.bPatterns) > 0 {
+		for field, patterns := range f.bPatterns {
+			val, ok := obj.GetField(field)
+			if !ok || !patterns.matchBool(val, f.tryStingNumbers) {
+				return false
 			}
+		}
+	}
+	for _, field := range f.nulls {
+		if val, ok := obj.GetField(field); ok && val != nil {
+			return false
+		}
+	}
+	for _, field := range f.sOrder {
+		val, ok := obj.GetField(field)
+		if !ok || !f.matchField(obj, f.sPatterns[field], val) {
 			return false
 		}
 	}
 	return true
 }
 
+// matchField uses literal hits from prefilter if event was wrapped by it
+func (f *Fields) matchField(obj EventChecker, p stringPatterns, val interface{}) bool {
+	if e, ok := p.prefiltered(obj); ok {
+		if p.prefilter.any(e.fieldHits(p.prefilter.automaton, val)) {
+			return true
+		}
+		p.literals = nil
+	}
+	return matchValue(p, f.toLower, val)
+}
+
+// matchValue applies string patterns to an event value of any scalar or list type
+func matchValue(p stringPatterns, lowercase bool, val interface{}) bool {
+	switch v := val.(type) {
+	case nil:
+		return false
+	case string:
+		return matchKeywords(p, lowercase, v)
+	case []string:
+		return matchKeywords(p, lowercase, v...)
+	case []interface{}:
+		for _, item := range v {
+			if matchValue(p, lowercase, item) {
+				return true
+			}
+		}
+		return false
+	}
+	if str := formatScalar(val); str != "" {
+		return matchKeywords(p, lowercase, str)
+	}
+	return false
+}
+
+// formatScalar formats numbers and booleans, empty string is returned for other types
+func formatScalar(val interface{}) string {
+	switch v := val.(type) {
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	if n, ok := toFloat(val); ok {
+		return strconv.FormatFloat(n, 'f', -1, 64)
+	}
+	return ""
+}
+
+// toFloat converts any go numeric type to float64
+func toFloat(val interface{}) (float64, bool) {
+	switch v := val.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// Self returns a copy of the selection, use Snapshot to read its counters
 func (f Fields) Self() interface{} { return f }
 
+// Ident returns name of detection identifier the selection was defined in
+func (f Fields) Ident() string { return f.ident }
+
+// Stats counts evaluations of a rule leaf
+// Counters are updated atomically, Snapshot should be used to read them while rules are in use
 type Stats struct {
 	Hits, Total int64
-	Took
 }
 
-type Took struct {
-	Min, Max, Avg time.Duration
-	ringBuffer    chan time.Duration
+func (s *Stats) record(match bool) bool {
+	atomic.AddInt64(&s.Total, 1)
+	if match {
+		atomic.AddInt64(&s.Hits, 1)
+	}
+	return match
+}
+
+// Snapshot returns a consistent copy of counters
+func (s *Stats) Snapshot() Stats {
+	return Stats{
+		Hits:  atomic.LoadInt64(&s.Hits),
+		Total: atomic.LoadInt64(&s.Total),
+	}
 }
@@ -168,6 +168,9 @@ func TestInputParse(t *testing.T) {
 		if err != nil {
 			t.Fatalf("%s | %s", in.raw_rule, err)
 		}
+		if _, ok := rule.Self().(Fields); !ok {
+			t.Fatalf("Self should return Fields value, got %T", rule.Self())
+		}
 		in.rule = rule
 	}
 	for _, in := range inputs {
@@ -247,6 +250,9 @@ func TestKeyword(t *testing.T) {
 	if !rule.Match(dummyKw(kw_example1_positive_case_0)) {
 		t.Fatalf("%+v\n", rule)
 	}
+	if _, ok := rule.Self().(Keyword); !ok {
+		t.Fatalf("Self should return Keyword value, got %T", rule.Self())
+	}
 }
 
 func BenchmarkKeyword(b *testing.B) {
@@ -257,3 +263,67 @@ func BenchmarkKeyword(b *testing.B) {
 		rule.Match(dummyKw(kw_example1_positive_case_0))
 	}
 }
+
+func TestFieldsTypedValues(t *testing.T) {
+	event := dummyObject{
+		"tags":       []interface{}{"attack.t1059", "attack.execution"},
+		"names":      []string{"cmd.exe", "powershell.exe"},
+		"ports":      []interface{}{float64(80), float64(443)},
+		"EventID":    "4624",
+		"pid":        int64(4242),
+		"elevated":   true,
+		"sticky":     "False",
+		"ratio":      0.5,
+		"nothing":    nil,
+		"empty_list": []interface{}{},
+	}
+	for _, tc := range []struct {
+		pattern map[string]interface{}
+		match   bool
+	}{
+		{map[string]interface{}{"tags": "attack.execution"}, true},
+		{map[string]interface{}{"tags": "attack.t1003"}, false},
+		{map[string]interface{}{"tags|contains": []interface{}{"*t1059*", "other"}}, true},
+		{map[string]interface{}{"names": "powershell*"}, true},
+		{map[string]interface{}{"ports": 443}, true},
+		{map[string]interface{}{"ports": []interface{}{22, 23}}, false},
+		{map[string]interface{}{"ports": "443"}, true},
+		{map[string]interface{}{"EventID": 4624}, true},
+		{map[string]interface{}{"EventID": []interface{}{4625, 4624}}, true},
+		{map[string]interface{}{"pid": 4242}, true},
+		{map[string]interface{}{"pid": "4242"}, true},
+		{map[string]interface{}{"ratio": "0.5"}, true},
+		{map[string]interface{}{"elevated": true}, true},
+		{map[string]interface{}{"elevated": false}, false},
+		{map[string]interface{}{"elevated": "true"}, true},
+		{map[string]interface{}{"sticky": false}, true},
+		{map[string]interface{}{"EventID": true}, false},
+		{map[string]interface{}{"nothing": nil}, true},
+		{map[string]interface{}{"missing": nil}, true},
+		{map[string]interface{}{"pid": nil}, false},
+		{map[string]interface{}{"nothing": "*"}, false},
+		{map[string]interface{}{"missing": "*"}, false},
+		{map[string]interface{}{"empty_list": "*"}, false},
+		{map[string]interface{}{"EventID": []interface{}{4624, "4625"}}, true},
+		{map[string]interface{}{"pid": 4242, "elevated": true, "tags": "attack.t1059", "missing": nil}, true},
+		{map[string]interface{}{"pid": 4242, "elevated": false}, false},
+	} {
+		f, err := NewFields(tc.pattern, false, true)
+		if err != nil {
+			t.Fatalf("%+v: %s", tc.pattern, err)
+		}
+		if f.Match(event) != tc.match {
+			t.Fatalf("%+v: expected match %t", tc.pattern, tc.match)
+		}
+	}
+	f, err := NewFields(map[string]interface{}{"EventID": 4624}, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Match(event) {
+		t.Fatal("string value should not match number pattern without string numbers")
+	}
+	if _, err := NewFields(map[string]interface{}{"pid": []interface{}{nil, 1}}, false, true); err == nil {
+		t.Fatal("null in list should return error")
+	}
+}
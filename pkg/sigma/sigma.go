@@ -7,7 +7,10 @@ import (
 	"os"
 	"os/user"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"gopkg.in/yaml.v2"
 )
@@ -18,6 +21,30 @@ import (
 
 type Config struct {
 	Directories []string
+
+	// Duplicates defines how rules sharing the same ID are handled
+	// Directories are layered in order, so DuplicateOverride lets a later directory replace rules from an earlier one
+	Duplicates DuplicatePolicy
+	// StrictID marks rules with missing or malformed UUID as broken
+	StrictID bool
+	// StrictSchema marks rules that violate sigma specification as broken
+	StrictSchema bool
+
+	// Mappings are names of bundled pipelines, applied before Pipelines
+	Mappings []string
+	// Pipelines translate rule fields and logsources to event schema, applied in order when rules are loaded
+	Pipelines []*Pipeline
+
+	// MatchDetails adds matched detection identifiers and field values to results
+	// Matching is slower when enabled
+	MatchDetails bool
+
+	// RegexLimits reject rules with overly complex regular expressions as broken
+	RegexLimits RegexLimits
+
+	// Metrics records loads of the ruleset and events it evaluates, optional
+	// The same collector can be passed to every reload of the ruleset
+	Metrics *Metrics
 }
 
 func (c *Config) Validate() error {
@@ -35,6 +62,51 @@ func (c *Config) Validate() error {
 	return nil
 }
 
+// DuplicatePolicy defines how NewRuleset handles multiple rules with identical ID
+type DuplicatePolicy int
+
+const (
+	// DuplicateIgnore loads all rules regardless of ID collisions
+	DuplicateIgnore DuplicatePolicy = iota
+	// DuplicateReject drops every rule that shares an ID with another rule
+	DuplicateReject
+	// DuplicateKeepFirst keeps the first rule and drops any subsequent rule with the same ID
+	DuplicateKeepFirst
+	// DuplicateOverride lets a rule from a later directory replace a rule with the same ID from an earlier one
+	// Collisions within a single directory are handled like DuplicateKeepFirst
+	DuplicateOverride
+)
+
+func (d DuplicatePolicy) String() string {
+	switch d {
+	case DuplicateIgnore:
+		return "ignore"
+	case DuplicateReject:
+		return "reject"
+	case DuplicateKeepFirst:
+		return "keep-first"
+	case DuplicateOverride:
+		return "override"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseDuplicatePolicy is the inverse of DuplicatePolicy.String
+func ParseDuplicatePolicy(s string) (DuplicatePolicy, error) {
+	switch strings.ToLower(s) {
+	case "", "ignore":
+		return DuplicateIgnore, nil
+	case "reject":
+		return DuplicateReject, nil
+	case "keep-first", "first":
+		return DuplicateKeepFirst, nil
+	case "override":
+		return DuplicateOverride, nil
+	}
+	return DuplicateIgnore, fmt.Errorf("unknown duplicate rule ID policy %s", s)
+}
+
 type UnsupportedRawRule struct {
 	Path   string
 	Reason string
@@ -52,21 +124,81 @@ func (u UnsupportedRawRule) Raw() string {
 
 type Rule struct {
 	tree *Tree
+	// detection as written in the rule, before optimization
+	source *Tree
 	RawRule
 	Path string
+
+	// Metadata holds typed attributes from RawRule
+	Metadata Metadata
+
+	// index of config directory the rule was loaded from
+	layer int
+	// record matched selections
+	details bool
+	// shared by copies of the rule
+	stats *ruleCounters
+}
+
+// Tree returns compiled detection of the rule
+func (r Rule) Tree() *Tree { return r.tree }
+
+// Source returns detection of the rule as written, before optimization
+// Rules built without it fall back to compiled detection
+func (r Rule) Source() *Tree {
+	if r.source != nil {
+		return r.source
+	}
+	return r.tree
+}
+
+// Check evaluates a single rule against the event
+func (r *Rule) Check(obj EventChecker) (Result, bool) {
+	var (
+		selections []MatchedSelection
+		match      bool
+		start      time.Time
+	)
+	sampled := r.stats != nil && r.stats.sample()
+	if sampled {
+		start = time.Now()
+	}
+	if r.details {
+		match, selections = r.tree.MatchDetails(obj)
+	} else {
+		match = r.tree.Match(obj)
+	}
+	if sampled {
+		r.stats.took.observe(time.Since(start))
+	}
+	if !match {
+		return Result{}, false
+	}
+	if r.stats != nil {
+		atomic.AddInt64(&r.stats.hits, 1)
+	}
+	return Result{
+		Tags:           r.Tags,
+		ID:             r.ID,
+		Title:          r.Title,
+		Level:          r.Metadata.Level,
+		Status:         r.Metadata.Status,
+		Description:    r.Description,
+		References:     r.References,
+		Falsepositives: r.Metadata.Falsepositives,
+		Logsource:      r.Logsource,
+		Path:           r.Path,
+		Selections:     selections,
+	}, true
 }
 
 type RuleGroup []Rule
 
 func (r RuleGroup) Check(obj EventChecker, firstmatch bool) (Results, bool) {
 	res := make(Results, 0)
-	for _, rule := range r {
-		if rule.tree.Match(obj) {
-			res = append(res, Result{
-				Tags:  rule.Tags,
-				ID:    rule.ID,
-				Title: rule.Title,
-			})
+	for i := range r {
+		if result, ok := r[i].Check(obj); ok {
+			res = append(res, result)
 			if len(res) == 1 && firstmatch {
 				return res, true
 			}
@@ -99,83 +231,286 @@ func (r RuleMap) Check(obj EventChecker, rulegroup string, firstmatch bool) (Res
 	return nil, false
 }
 
+// LogsourceMap routes rules by product, category and service of their logsource
+// Attributes missing from rule logsource act as wildcards, so a rule that only defines category
+// applies to events of that category regardless of product or service
+type LogsourceMap map[Logsource]RuleGroup
+
+// Add stores rule under its normalized logsource
+func (l LogsourceMap) Add(rule Rule) {
+	key := rule.Logsource.key()
+	l[key] = append(l[key], rule)
+}
+
+// Get returns all rule groups applicable to an event from described logsource
+// Attributes missing from event logsource only match rules that do not require them
+func (l LogsourceMap) Get(ls Logsource) []RuleGroup {
+	out := make([]RuleGroup, 0)
+	for _, key := range ls.routes() {
+		if group, ok := l[key]; ok {
+			out = append(out, group)
+		}
+	}
+	return out
+}
+
+// routes returns keys of every rule group applicable to an event from logsource
+func (l Logsource) routes() []Logsource {
+	l = l.key()
+	variants := func(val string) []string {
+		if val == "" {
+			return []string{""}
+		}
+		return []string{val, ""}
+	}
+	out := make([]Logsource, 0, 8)
+	for _, product := range variants(l.Product) {
+		for _, category := range variants(l.Category) {
+			for _, service := range variants(l.Service) {
+				out = append(out, Logsource{
+					Product:  product,
+					Category: category,
+					Service:  service,
+				})
+			}
+		}
+	}
+	return out
+}
+
+// Check evaluates only the rules that apply to event logsource
+func (l LogsourceMap) Check(obj EventChecker, ls Logsource, firstmatch bool) (Results, bool) {
+	res := make(Results, 0)
+	for _, group := range l.Get(ls) {
+		if found, ok := group.Check(obj, firstmatch); ok {
+			res = append(res, found...)
+			if firstmatch {
+				return res, true
+			}
+		}
+	}
+	if len(res) > 0 {
+		return res, true
+	}
+	return nil, false
+}
+
 type Ruleset struct {
 	dirs []string
 
+	// Rules holds rules grouped by logsource product, rules without product are not included
 	Rules RuleMap
+	// Logsources routes every loaded rule by logsource product, category and service
+	// Check uses an index built from Logsources when rules are loaded, later changes to the map are not indexed
+	Logsources LogsourceMap
 
 	Total       int
 	Unsupported []UnsupportedRawRule
 	Broken      []UnsupportedRawRule
+
+	// index over Logsources, built once when rules are loaded
+	index map[Logsource]*RuleIndex
+	// literal prefilter over all loaded rules
+	prefilter *Prefilter
+	// engine counters, optional
+	metrics *Metrics
+}
+
+// Check evaluates event against rules applicable to its logsource
+// Only candidate rules selected by field index are evaluated and literal patterns are looked up from a single
+// scan of every field, results are identical to Logsources.Check
+func (r Ruleset) Check(obj EventChecker, ls Logsource, firstmatch bool) (Results, bool) {
+	res, ok := r.check(obj, ls, firstmatch)
+	if r.metrics != nil {
+		r.metrics.checked(res)
+	}
+	return res, ok
+}
+
+func (r Ruleset) check(obj EventChecker, ls Logsource, firstmatch bool) (Results, bool) {
+	if r.index == nil {
+		return r.Logsources.Check(obj, ls, firstmatch)
+	}
+	if r.prefilter != nil {
+		e := r.prefilter.Wrap(obj)
+		defer e.Release()
+		obj = e
+	}
+	res := make(Results, 0)
+	for _, key := range ls.routes() {
+		idx, ok := r.index[key]
+		if !ok {
+			continue
+		}
+		if found, ok := idx.Check(obj, firstmatch); ok {
+			res = append(res, found...)
+			if firstmatch {
+				return res, true
+			}
+		}
+	}
+	if len(res) > 0 {
+		return res, true
+	}
+	return nil, false
+}
+
+// CheckEvent evaluates event against rules applicable to logsource reported by the event itself
+// Events that do not implement LogsourceGetter do not match any rule
+func (r Ruleset) CheckEvent(obj EventChecker, firstmatch bool) (Results, bool) {
+	if ls, ok := obj.(LogsourceGetter); ok {
+		return r.Check(obj, ls.GetLogsource(), firstmatch)
+	}
+	if r.metrics != nil {
+		r.metrics.checked(nil)
+	}
+	return nil, false
+}
+
+// LoadRule parses a single rule file and applies pipelines to it
+// Unlike NewRuleset, any problem with the rule is returned as error
+// Zero value of limits checks regular expressions against DefaultRegexLimits
+func LoadRule(path string, limits RegexLimits, pipelines ...*Pipeline) (*Rule, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Contains(data, []byte("---")) {
+		return nil, fmt.Errorf("%s: multi-part YAML is not supported", path)
+	}
+	var raw RawRule
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	rule, _, err := compileRule(raw, path, pipelines, limits, false)
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// ResolvePipelines returns bundled mappings followed by pipelines, in the order they are applied to rules
+func ResolvePipelines(mappings []string, pipelines ...*Pipeline) ([]*Pipeline, error) {
+	out := make([]*Pipeline, 0, len(mappings)+len(pipelines))
+	for _, name := range mappings {
+		p, err := BundledPipeline(name)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return append(out, pipelines...), nil
+}
+
+// compileRule applies pipelines to a decoded rule, validates it and builds its detection tree
+// Returned rule holds translated RawRule even on error, so the failure can be reported with it
+// Specification violations of translated rule are returned regardless of strict, which only makes them an error
+func compileRule(raw RawRule, path string, pipelines []*Pipeline, limits RegexLimits, strict bool) (Rule, []SpecViolation, error) {
+	rule := Rule{RawRule: raw, Path: path}
+	conditions := make([]map[string]interface{}, 0)
+	for _, p := range pipelines {
+		applied, extra, err := p.Apply(rule.RawRule)
+		if err != nil {
+			return rule, nil, err
+		}
+		rule.RawRule = applied
+		conditions = append(conditions, extra...)
+	}
+	meta, violations := rule.Validate()
+	if strict && len(violations) > 0 {
+		return rule, violations, ErrSpecViolations(violations)
+	}
+	budget := newRegexBudget(limits)
+	tree, err := parseDetection(rule.Detection, RuleConfig{regex: budget})
+	if err == nil && len(conditions) > 0 {
+		tree, err = withConditions(tree, rule.Detection, conditions, budget)
+	}
+	if err != nil {
+		return rule, violations, err
+	}
+	rule.tree, rule.source = tree.Optimize(), tree
+	rule.Metadata = meta
+	rule.stats = &ruleCounters{}
+	return rule, violations, nil
 }
 
 func NewRuleset(c *Config) (*Ruleset, error) {
+	r, err := newRuleset(c)
+	if c.Metrics != nil {
+		c.Metrics.loaded(r, err)
+	}
+	return r, err
+}
+
+func newRuleset(c *Config) (*Ruleset, error) {
 	if err := c.Validate(); err != nil {
 		return nil, err
 	}
+	pipelines, err := ResolvePipelines(c.Mappings, c.Pipelines...)
+	if err != nil {
+		return nil, err
+	}
 	r := &Ruleset{
 		dirs:        c.Directories,
 		Rules:       make(map[string]RuleGroup),
+		Logsources:  make(LogsourceMap),
 		Unsupported: make([]UnsupportedRawRule, 0),
 		Broken:      make([]UnsupportedRawRule, 0),
-	}
-	files, err := discoverRuleFilesInDir(r.dirs)
-	if err != nil {
-		return nil, err
+		metrics:     c.Metrics,
 	}
 	decoded := make([]Rule, 0)
-loop:
-	for _, path := range files {
-		data, err := ioutil.ReadFile(path) // just pass the file name
+	for layer, dir := range r.dirs {
+		files, err := discoverRuleFilesInDir([]string{dir})
 		if err != nil {
 			return nil, err
 		}
-		if bytes.Contains(data, []byte("---")) {
-			r.Unsupported = append(r.Unsupported, UnsupportedRawRule{
-				Path:   path,
-				Reason: "Multi-part YAML",
-				Error:  nil,
+	loop:
+		for _, path := range files {
+			data, err := ioutil.ReadFile(path) // just pass the file name
+			if err != nil {
+				return nil, err
+			}
+			if bytes.Contains(data, []byte("---")) {
+				r.Unsupported = append(r.Unsupported, UnsupportedRawRule{
+					Path:   path,
+					Reason: "Multi-part YAML",
+					Error:  nil,
+				})
+				continue loop
+			}
+			var s RawRule
+			if err := yaml.Unmarshal([]byte(data), &s); err != nil {
+				return nil, err
+			}
+			decoded = append(decoded, Rule{
+				RawRule: s,
+				Path:    path,
+				layer:   layer,
 			})
-			continue loop
 		}
-		var s RawRule
-		if err := yaml.Unmarshal([]byte(data), &s); err != nil {
-			return nil, err
-		}
-		decoded = append(decoded, Rule{
-			RawRule: s,
-			Path:    path,
-		})
 	}
 	rules := make([]Rule, 0)
-
-decodedloop:
 	for _, dec := range decoded {
-		tree, err := ParseDetection(dec.Detection)
+		rule, _, err := compileRule(dec.RawRule, dec.Path, pipelines, c.RegexLimits, c.StrictSchema)
 		if err != nil {
+			failed := UnsupportedRawRule{
+				Path:  dec.Path,
+				Rule:  &rule.RawRule,
+				Error: err,
+			}
 			switch err.(type) {
-			case *ErrUnsupportedToken, *ErrIncompleteDetection, *ErrWip, ErrUnsupportedToken, ErrIncompleteDetection, ErrWip:
-				r.Unsupported = append(r.Unsupported, UnsupportedRawRule{
-					Path:  dec.Path,
-					Rule:  &dec.RawRule,
-					Error: err,
-				})
-				continue decodedloop
+			case ErrUnmappedFields, *ErrUnsupportedToken, *ErrIncompleteDetection, *ErrWip, ErrUnsupportedToken, ErrIncompleteDetection, ErrWip:
+				r.Unsupported = append(r.Unsupported, failed)
 			default:
-				r.Broken = append(r.Broken, UnsupportedRawRule{
-					Path:  dec.Path,
-					Rule:  &dec.RawRule,
-					Error: err,
-				})
-				continue decodedloop
+				r.Broken = append(r.Broken, failed)
 			}
+			continue
 		}
-		rules = append(rules, Rule{
-			tree:    tree,
-			RawRule: dec.RawRule,
-			Path:    dec.Path,
-		})
+		rule.layer = dec.layer
+		rule.details = c.MatchDetails
+		rules = append(rules, rule)
 	}
+	rules = r.checkIDs(rules, c.Duplicates, c.StrictID)
 	if len(rules) == 0 {
 		return r, fmt.Errorf("unable to parse any rules from %+v", r.dirs)
 	}
@@ -183,15 +518,19 @@ decodedloop:
 
 groupLoop:
 	for _, rule := range rules {
-		if rule.Logsource.Product == "" {
+		if ls := rule.Logsource.key(); ls.Product == "" && ls.Category == "" && ls.Service == "" {
 			r.Unsupported = append(r.Unsupported, UnsupportedRawRule{
 				Rule:   &rule.RawRule,
-				Reason: "Missing PRODUCT in LOGSOURCE",
+				Reason: "Missing PRODUCT, CATEGORY and SERVICE in LOGSOURCE",
 				Path:   rule.Path,
 			})
 			r.Total--
 			continue groupLoop
 		}
+		r.Logsources.Add(rule)
+		if rule.Logsource.Product == "" {
+			continue groupLoop
+		}
 		if val, ok := r.Rules[rule.Logsource.Product]; ok {
 			r.Rules[rule.Logsource.Product] = append(val, rule)
 		} else {
@@ -199,9 +538,86 @@ groupLoop:
 			r.Rules[rule.Logsource.Product][0] = rule
 		}
 	}
+	r.index = make(map[Logsource]*RuleIndex, len(r.Logsources))
+	all := make([]Rule, 0, r.Total)
+	for key, group := range r.Logsources {
+		r.index[key] = NewRuleIndex(group)
+		all = append(all, group...)
+	}
+	r.prefilter = NewPrefilter(all...)
 	return r, nil
 }
 
+// checkIDs validates rule ID format and uniqueness, moving offending rules to Broken or Unsupported
+func (r *Ruleset) checkIDs(rules []Rule, policy DuplicatePolicy, strict bool) []Rule {
+	var (
+		out      = make([]Rule, 0, len(rules))
+		seen     = make(map[string]int)
+		rejected = make(map[string]bool)
+		dropped  = make(map[int]bool)
+	)
+	broken := func(rule Rule, err error) {
+		r.Broken = append(r.Broken, UnsupportedRawRule{
+			Path:  rule.Path,
+			Rule:  &rule.RawRule,
+			Error: err,
+		})
+	}
+	for _, rule := range rules {
+		if strict && !isUUID(rule.ID) {
+			broken(rule, ErrInvalidID{ID: rule.ID})
+			continue
+		}
+		if policy == DuplicateIgnore || rule.ID == "" {
+			out = append(out, rule)
+			continue
+		}
+		if rejected[rule.ID] {
+			broken(rule, ErrDuplicateID{ID: rule.ID, Paths: []string{rule.Path}})
+			continue
+		}
+		idx, ok := seen[rule.ID]
+		if !ok {
+			seen[rule.ID] = len(out)
+			out = append(out, rule)
+			continue
+		}
+		prev := out[idx]
+		switch {
+		case policy == DuplicateReject:
+			err := ErrDuplicateID{ID: rule.ID, Paths: []string{prev.Path, rule.Path}}
+			broken(prev, err)
+			broken(rule, err)
+			dropped[idx] = true
+			rejected[rule.ID] = true
+			delete(seen, rule.ID)
+		case policy == DuplicateOverride && rule.layer > prev.layer:
+			r.Unsupported = append(r.Unsupported, UnsupportedRawRule{
+				Path:   prev.Path,
+				Rule:   &prev.RawRule,
+				Reason: fmt.Sprintf("Rule ID %s overridden by %s", rule.ID, rule.Path),
+			})
+			out[idx] = rule
+		default:
+			broken(rule, ErrDuplicateID{ID: rule.ID, Paths: []string{prev.Path, rule.Path}})
+		}
+	}
+	if len(dropped) == 0 {
+		return out
+	}
+	kept := make([]Rule, 0, len(out)-len(dropped))
+	for i, rule := range out {
+		if !dropped[i] {
+			kept = append(kept, rule)
+		}
+	}
+	return kept
+}
+
+var uuidRegex = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+func isUUID(s string) bool { return uuidRegex.MatchString(s) }
+
 func discoverRuleFilesInDir(dirs []string) ([]string, error) {
 	out := make([]string, 0)
 	for _, dir := range dirs {
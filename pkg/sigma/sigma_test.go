@@ -0,0 +1,225 @@
+package sigma
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const rulesetTemplate = `title: %s
+id: %s
+status: experimental
+logsource:
+%s
+detection:
+    selection:
+        %s: '%s'
+    condition: selection
+level: high
+`
+
+type testRuleFile struct {
+	name, id, title, pattern string
+	// logsource block in yaml, defaults to windows product
+	logsource string
+	// selection field, defaults to CommandLine
+	field string
+}
+
+func writeTestRules(t *testing.T, dir string, rules ...testRuleFile) {
+	for _, rule := range rules {
+		ls := rule.logsource
+		if ls == "" {
+			ls = "    product: windows"
+		}
+		field := rule.field
+		if field == "" {
+			field = "CommandLine"
+		}
+		data := fmt.Sprintf(rulesetTemplate, rule.title, rule.id, ls, field, rule.pattern)
+		if err := ioutil.WriteFile(filepath.Join(dir, rule.name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func newTestRuleDirs(t *testing.T, layers ...[]testRuleFile) ([]string, func()) {
+	root, err := ioutil.TempDir("", "sigma-rules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dirs := make([]string, len(layers))
+	for i, rules := range layers {
+		dirs[i] = filepath.Join(root, fmt.Sprintf("layer%d", i))
+		if err := os.Mkdir(dirs[i], 0755); err != nil {
+			t.Fatal(err)
+		}
+		writeTestRules(t, dirs[i], rules...)
+	}
+	return dirs, func() { os.RemoveAll(root) }
+}
+
+const (
+	testID1 = "5f1abf38-3f4d-4bd6-b8e2-6d4b1d8e0a01"
+	testID2 = "5f1abf38-3f4d-4bd6-b8e2-6d4b1d8e0a02"
+)
+
+var duplicateLayers = [][]testRuleFile{
+	{
+		{name: "a.yml", id: testID1, title: "upstream", pattern: "upstream"},
+		{name: "b.yml", id: testID2, title: "other", pattern: "other"},
+	},
+	{
+		{name: "a.yml", id: testID1, title: "local", pattern: "local"},
+	},
+}
+
+func TestRulesetDuplicates(t *testing.T) {
+	dirs, cleanup := newTestRuleDirs(t, duplicateLayers...)
+	defer cleanup()
+
+	for _, c := range []struct {
+		policy DuplicatePolicy
+		total  int
+		broken int
+		title  string
+	}{
+		{policy: DuplicateIgnore, total: 3},
+		{policy: DuplicateReject, total: 1, broken: 2},
+		{policy: DuplicateKeepFirst, total: 2, broken: 1, title: "upstream"},
+		{policy: DuplicateOverride, total: 2, title: "local"},
+	} {
+		r, err := NewRuleset(&Config{Directories: dirs, Duplicates: c.policy})
+		if err != nil {
+			t.Fatalf("%s: %s", c.policy, err)
+		}
+		if r.Total != c.total || len(r.Broken) != c.broken {
+			t.Fatalf("%s: expected %d rules and %d broken, got %d and %d",
+				c.policy, c.total, c.broken, r.Total, len(r.Broken))
+		}
+		if c.title == "" {
+			continue
+		}
+		for _, rule := range r.Rules["windows"] {
+			if rule.ID == testID1 && rule.Title != c.title {
+				t.Fatalf("%s: expected %s rule to be kept, got %s", c.policy, c.title, rule.Title)
+			}
+		}
+	}
+}
+
+func TestRulesetStrictID(t *testing.T) {
+	dirs, cleanup := newTestRuleDirs(t, []testRuleFile{
+		{name: "a.yml", id: testID1, title: "valid", pattern: "a"},
+		{name: "b.yml", id: "not-a-uuid", title: "invalid", pattern: "b"},
+	})
+	defer cleanup()
+
+	r, err := NewRuleset(&Config{Directories: dirs, StrictID: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Total != 1 || len(r.Broken) != 1 {
+		t.Fatalf("expected 1 valid and 1 broken rule, got %d and %d", r.Total, len(r.Broken))
+	}
+	if _, ok := r.Broken[0].Error.(ErrInvalidID); !ok {
+		t.Fatalf("expected ErrInvalidID, got %+v", r.Broken[0].Error)
+	}
+}
+
+func TestParseDuplicatePolicy(t *testing.T) {
+	for _, p := range []DuplicatePolicy{DuplicateIgnore, DuplicateReject, DuplicateKeepFirst, DuplicateOverride} {
+		parsed, err := ParseDuplicatePolicy(p.String())
+		if err != nil || parsed != p {
+			t.Fatalf("%s did not round trip, got %s %v", p, parsed, err)
+		}
+	}
+	if _, err := ParseDuplicatePolicy("bogus"); err == nil {
+		t.Fatal("invalid policy should return error")
+	}
+}
+
+func TestRulesetLogsourceRouting(t *testing.T) {
+	dirs, cleanup := newTestRuleDirs(t, []testRuleFile{
+		{name: "product.yml", title: "product", pattern: "whoami",
+			logsource: "    product: windows"},
+		{name: "category.yml", title: "category", pattern: "whoami",
+			logsource: "    category: process_creation"},
+		{name: "full.yml", title: "full", pattern: "whoami",
+			logsource: "    product: windows\n    category: process_creation\n    service: sysmon"},
+		{name: "linux.yml", title: "linux", pattern: "whoami",
+			logsource: "    product: linux"},
+		{name: "none.yml", title: "none", pattern: "whoami",
+			logsource: "    definition: nothing to route on"},
+	})
+	defer cleanup()
+
+	r, err := NewRuleset(&Config{Directories: dirs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Total != 4 || len(r.Unsupported) != 1 {
+		t.Fatalf("expected 4 rules and 1 unsupported, got %d and %d", r.Total, len(r.Unsupported))
+	}
+	event := dummyObject{"CommandLine": "cmd.exe /c whoami"}
+	for _, c := range []struct {
+		ls     Logsource
+		titles []string
+	}{
+		{ls: Logsource{Product: "windows"}, titles: []string{"product"}},
+		{ls: Logsource{Product: "Windows", Category: "process_creation"}, titles: []string{"product", "category"}},
+		{ls: Logsource{Product: "windows", Category: "process_creation", Service: "sysmon"}, titles: []string{"full", "product", "category"}},
+		{ls: Logsource{Category: "process_creation"}, titles: []string{"category"}},
+		{ls: Logsource{Product: "linux", Category: "network_connection"}, titles: []string{"linux"}},
+		{ls: Logsource{Product: "macos"}},
+	} {
+		res, ok := r.Check(event, c.ls, false)
+		if ok != (len(c.titles) > 0) || len(res) != len(c.titles) {
+			t.Fatalf("%+v: expected %v, got %+v", c.ls, c.titles, res)
+		}
+		found := make(map[string]bool)
+		for _, item := range res {
+			found[item.Title] = true
+		}
+		for _, title := range c.titles {
+			if !found[title] {
+				t.Fatalf("%+v: expected %v, got %+v", c.ls, c.titles, res)
+			}
+		}
+	}
+}
+
+func TestRulesetResultDetails(t *testing.T) {
+	dirs, cleanup := newTestRuleDirs(t, []testRuleFile{
+		{name: "a.yml", id: testID1, title: "whoami", pattern: "*whoami*"},
+	})
+	defer cleanup()
+
+	event := dummyObject{"CommandLine": "cmd.exe /c whoami"}
+	for _, details := range []bool{false, true} {
+		r, err := NewRuleset(&Config{Directories: dirs, MatchDetails: details})
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, ok := r.Check(event, Logsource{Product: "windows"}, true)
+		if !ok || len(res) != 1 {
+			t.Fatalf("expected a single match, got %+v", res)
+		}
+		if res[0].Level != LevelHigh || res[0].Status != StatusExperimental ||
+			res[0].Logsource.Product != "windows" || filepath.Base(res[0].Path) != "a.yml" {
+			t.Fatalf("missing rule metadata in %+v", res[0])
+		}
+		if !details {
+			if res[0].Selections != nil {
+				t.Fatalf("selections should only be collected on request, got %+v", res[0].Selections)
+			}
+			continue
+		}
+		if len(res[0].Selections) != 1 || res[0].Selections[0].Name != "selection" ||
+			res[0].Selections[0].Fields["CommandLine"] != "cmd.exe /c whoami" {
+			t.Fatalf("wrong selections %+v", res[0].Selections)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package sigma
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// Rule evaluation latency is measured for one in statsSampleRate evaluations, as reading the clock
+// costs about as much as evaluating a simple rule
+const statsSampleRate = 64
+
+// Took is a latency histogram with exponential buckets
+// Bucket i counts durations up to tookBase << i, last bucket counts everything above
+type Took struct {
+	buckets  [tookBuckets]int64
+	sum, max int64
+}
+
+const (
+	tookBase    = 64 * time.Nanosecond
+	tookBuckets = 26
+)
+
+func (t *Took) observe(d time.Duration) {
+	i := 0
+	for i < tookBuckets-1 && d > tookBase<<uint(i) {
+		i++
+	}
+	atomic.AddInt64(&t.buckets[i], 1)
+	atomic.AddInt64(&t.sum, int64(d))
+	for {
+		max := atomic.LoadInt64(&t.max)
+		if int64(d) <= max || atomic.CompareAndSwapInt64(&t.max, max, int64(d)) {
+			return
+		}
+	}
+}
+
+// Latency is a snapshot of a latency histogram
+// Percentiles are upper bounds of histogram buckets, so they overestimate by up to a factor of two
+type Latency struct {
+	Samples       int64
+	P50, P90, P99 time.Duration
+	Max           time.Duration
+	// Sum of all sampled durations
+	Sum time.Duration
+}
+
+func (t *Took) snapshot() Latency {
+	var (
+		counts [tookBuckets]int64
+		l      Latency
+	)
+	for i := range counts {
+		counts[i] = atomic.LoadInt64(&t.buckets[i])
+		l.Samples += counts[i]
+	}
+	l.Max = time.Duration(atomic.LoadInt64(&t.max))
+	l.Sum = time.Duration(atomic.LoadInt64(&t.sum))
+	if l.Samples == 0 {
+		return l
+	}
+	percentile := func(p float64) time.Duration {
+		rank := int64(p*float64(l.Samples) + 0.5)
+		if rank < 1 {
+			rank = 1
+		}
+		var seen int64
+		for i, c := range counts {
+			if seen += c; seen >= rank {
+				if bound := tookBase << uint(i); i < tookBuckets-1 && bound < l.Max {
+					return bound
+				}
+				return l.Max
+			}
+		}
+		return l.Max
+	}
+	l.P50, l.P90, l.P99 = percentile(0.5), percentile(0.9), percentile(0.99)
+	return l
+}
+
+// ruleCounters are shared by all copies of a rule
+type ruleCounters struct {
+	evaluations, hits int64
+	took              Took
+}
+
+// sample counts an evaluation and reports if its latency should be measured
+func (c *ruleCounters) sample() bool {
+	return atomic.AddInt64(&c.evaluations, 1)%statsSampleRate == 1
+}
+
+// LeafStats holds counters of a single selection or keyword leaf of rule detection
+type LeafStats struct {
+	Ident string
+	Stats
+}
+
+// RuleStats is a snapshot of rule counters
+type RuleStats struct {
+	ID, Title, Path string
+
+	Evaluations, Hits int64
+	Latency           Latency
+
+	Leaves []LeafStats
+}
+
+// Stats returns counters of a rule, or nil if rule was not loaded by NewRuleset or LoadRule
+func (r *Rule) Stats() *RuleStats {
+	if r.stats == nil {
+		return nil
+	}
+	s := &RuleStats{
+		ID:          r.ID,
+		Title:       r.Title,
+		Path:        r.Path,
+		Evaluations: atomic.LoadInt64(&r.stats.evaluations),
+		Hits:        atomic.LoadInt64(&r.stats.hits),
+		Latency:     r.stats.took.snapshot(),
+		Leaves:      make([]LeafStats, 0),
+	}
+	Inspect(r.tree, func(b Branch) bool {
+		switch n := b.(type) {
+		case *Fields:
+			s.Leaves = append(s.Leaves, LeafStats{Ident: n.ident, Stats: n.Snapshot()})
+		case *Keyword:
+			s.Leaves = append(s.Leaves, LeafStats{Ident: n.ident, Stats: n.Snapshot()})
+		}
+		return true
+	})
+	return s
+}
+
+// Stats returns counters of every loaded rule sorted by path
+func (r Ruleset) Stats() []RuleStats {
+	out := make([]RuleStats, 0, r.Total)
+	for _, group := range r.Logsources {
+		for i := range group {
+			if s := group[i].Stats(); s != nil {
+				out = append(out, *s)
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Path != out[j].Path {
+			return out[i].Path < out[j].Path
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
@@ -0,0 +1,92 @@
+package sigma
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRulesetStats(t *testing.T) {
+	dirs, cleanup := newTestRuleDirs(t, []testRuleFile{
+		{name: "a.yml", id: testID1, title: "whoami", pattern: "*whoami*"},
+		{name: "b.yml", id: testID2, title: "never", pattern: "never"},
+	})
+	defer cleanup()
+
+	r, err := NewRuleset(&Config{Directories: dirs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		wg      sync.WaitGroup
+		workers = 8
+		events  = 500
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < events; i++ {
+				cmd := "cmd.exe /c dir"
+				if i%2 == 0 {
+					cmd = "cmd.exe /c whoami"
+				}
+				r.Check(dummyObject{"CommandLine": cmd}, Logsource{Product: "windows"}, false)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	stats := r.Stats()
+	if len(stats) != 2 || stats[0].Title != "whoami" || stats[1].Title != "never" {
+		t.Fatalf("wrong stats %+v", stats)
+	}
+	total := int64(workers * events)
+	for _, s := range stats {
+		if s.Evaluations != total {
+			t.Fatalf("%s: expected %d evaluations, got %d", s.Title, total, s.Evaluations)
+		}
+		if samples := (total + statsSampleRate - 1) / statsSampleRate; s.Latency.Samples != samples {
+			t.Fatalf("%s: expected %d samples, got %d", s.Title, samples, s.Latency.Samples)
+		}
+		if s.Latency.P50 > s.Latency.P99 || s.Latency.P99 > s.Latency.Max {
+			t.Fatalf("%s: percentiles out of order %+v", s.Title, s.Latency)
+		}
+		if len(s.Leaves) != 1 || s.Leaves[0].Ident != "selection" || s.Leaves[0].Total != total {
+			t.Fatalf("%s: wrong leaves %+v", s.Title, s.Leaves)
+		}
+	}
+	if stats[0].Hits != total/2 || stats[0].Leaves[0].Hits != total/2 {
+		t.Fatalf("expected %d hits, got %+v", total/2, stats[0])
+	}
+	if stats[1].Hits != 0 || stats[1].Leaves[0].Hits != 0 {
+		t.Fatalf("expected no hits, got %+v", stats[1])
+	}
+}
+
+func TestTookPercentiles(t *testing.T) {
+	var took Took
+	if l := took.snapshot(); l.Samples != 0 || l.P99 != 0 {
+		t.Fatalf("empty histogram should be zero, got %+v", l)
+	}
+	for i := 0; i < 90; i++ {
+		took.observe(100 * time.Nanosecond)
+	}
+	for i := 0; i < 9; i++ {
+		took.observe(10 * time.Microsecond)
+	}
+	took.observe(time.Millisecond)
+
+	l := took.snapshot()
+	if l.Samples != 100 || l.Max != time.Millisecond || l.Sum != 9000*time.Nanosecond+90*time.Microsecond+time.Millisecond {
+		t.Fatalf("wrong snapshot %+v", l)
+	}
+	// percentiles are bucket upper bounds
+	if l.P50 != 128*time.Nanosecond || l.P90 != 128*time.Nanosecond || l.P99 != 16384*time.Nanosecond {
+		t.Fatalf("wrong percentiles %+v", l)
+	}
+	took.observe(time.Hour)
+	if l := took.snapshot(); l.Max != time.Hour {
+		t.Fatalf("overflow bucket should report max, got %+v", l)
+	}
+}
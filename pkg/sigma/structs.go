@@ -0,0 +1,215 @@
+package sigma
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// StructEvent implements EventChecker for arbitrary go structs
+// Fields are resolved by sigma struct tag or by field name if tag is missing, for example
+//
+//	type Process struct {
+//		Cmd    string `sigma:"CommandLine,message"`
+//		Parent *Process
+//		Secret string `sigma:"-"`
+//	}
+//
+// exposes CommandLine, Parent.CommandLine and so on, while top level fields tagged with message option are returned by GetMessage
+// Embedded structs are promoted, maps with string keys are indexed by the remainder of the path
+// Field accessors are computed once per type and cached
+type StructEvent struct {
+	value  reflect.Value
+	schema *structSchema
+}
+
+type structSchema struct {
+	fields  map[string][]int
+	message []string
+}
+
+var structSchemas sync.Map
+
+// maxStructDepth limits nesting for self-referencing types such as a process with parent process
+const maxStructDepth = 5
+
+// NewStructEvent wraps a struct or a pointer to struct
+func NewStructEvent(v interface{}) (*StructEvent, error) {
+	val := reflect.ValueOf(v)
+	for val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return nil, fmt.Errorf("cannot create event from nil %T", v)
+		}
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("cannot create event from %T, expected struct", v)
+	}
+	return &StructEvent{value: val, schema: schemaOf(val.Type())}, nil
+}
+
+func schemaOf(t reflect.Type) *structSchema {
+	if s, ok := structSchemas.Load(t); ok {
+		return s.(*structSchema)
+	}
+	s := &structSchema{fields: make(map[string][]int)}
+	s.collect(t, "", nil, 0)
+	actual, _ := structSchemas.LoadOrStore(t, s)
+	return actual.(*structSchema)
+}
+
+func (s *structSchema) collect(t reflect.Type, prefix string, index []int, depth int) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+		tag := f.Tag.Get("sigma")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if j := strings.IndexByte(tag, ','); j >= 0 {
+			name, opts = tag[:j], tag[j+1:]
+		}
+		idx := make([]int, len(index)+1)
+		copy(idx, index)
+		idx[len(index)] = i
+
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" {
+			if ft.Kind() == reflect.Struct && depth < maxStructDepth {
+				s.collect(ft, prefix, idx, depth+1)
+			}
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		key := prefix + name
+		if _, exists := s.fields[key]; !exists {
+			s.fields[key] = idx
+		}
+		for _, opt := range strings.Split(opts, ",") {
+			// message option only applies to top level and promoted fields
+			if opt == "message" && prefix == "" {
+				s.message = append(s.message, key)
+			}
+		}
+		if ft.Kind() == reflect.Struct && hasExportedFields(ft) && depth < maxStructDepth {
+			s.collect(ft, key+".", idx, depth+1)
+		}
+	}
+}
+
+func hasExportedFields(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).PkgPath == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// GetMessage implements MessageGetter
+func (s StructEvent) GetMessage() []string {
+	out := make([]string, 0, len(s.schema.message))
+	for _, key := range s.schema.message {
+		if val, ok := s.GetField(key); ok {
+			out = appendMessage(out, val)
+		}
+	}
+	return out
+}
+
+// GetField implements SelectionGetter
+func (s StructEvent) GetField(key string) (interface{}, bool) {
+	if idx, ok := s.schema.fields[key]; ok {
+		if v, ok := fieldByIndex(s.value, idx); ok {
+			return plainValue(v)
+		}
+		return nil, false
+	}
+	// remainder of the path may index a map
+	for i := len(key) - 1; i > 0; i-- {
+		if key[i] != '.' {
+			continue
+		}
+		idx, ok := s.schema.fields[key[:i]]
+		if !ok {
+			continue
+		}
+		v, ok := fieldByIndex(s.value, idx)
+		if !ok || v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String {
+			return nil, false
+		}
+		item := v.MapIndex(reflect.ValueOf(key[i+1:]).Convert(v.Type().Key()))
+		if !item.IsValid() {
+			return nil, false
+		}
+		return plainValue(item)
+	}
+	return nil, false
+}
+
+// fieldByIndex is like reflect.Value.FieldByIndex, but reports nil pointers instead of panicking
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for _, i := range index {
+		for v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return v, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return v, false
+		}
+		v = v.Elem()
+	}
+	return v, true
+}
+
+// plainValue converts named types to basic types that are understood by rule matchers
+func plainValue(v reflect.Value) (interface{}, bool) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, true
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), true
+	case reflect.Bool:
+		return v.Bool(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint(), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			return string(v.Bytes()), true
+		}
+		out := make([]interface{}, v.Len())
+		for i := range out {
+			out[i], _ = plainValue(v.Index(i))
+		}
+		return out, true
+	}
+	if v.CanInterface() {
+		return v.Interface(), true
+	}
+	return nil, false
+}
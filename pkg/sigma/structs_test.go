@@ -0,0 +1,105 @@
+package sigma
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testHost struct {
+	Name string `sigma:"Computer"`
+}
+
+type testLabel string
+
+type testProcess struct {
+	testHost
+	PID         uint32
+	Image       string    `sigma:"Image,message"`
+	CommandLine testLabel `sigma:",message"`
+	Args        []string
+	Parent      *testProcess
+	Started     time.Time
+	Labels      map[string]string
+	Secret      string `sigma:"-"`
+	internal    string
+}
+
+func TestStructEvent(t *testing.T) {
+	p := &testProcess{
+		testHost:    testHost{Name: "WS01"},
+		PID:         4242,
+		Image:       `C:\Windows\System32\cmd.exe`,
+		CommandLine: "cmd.exe /c whoami",
+		Args:        []string{"/c", "whoami"},
+		Parent: &testProcess{
+			Image: `C:\Windows\explorer.exe`,
+		},
+		Labels:   map[string]string{"env": "prod"},
+		Secret:   "hunter2",
+		internal: "x",
+	}
+	e, err := NewStructEvent(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		key   string
+		value interface{}
+		found bool
+	}{
+		{key: "Computer", value: "WS01", found: true},
+		{key: "PID", value: uint64(4242), found: true},
+		{key: "Image", value: `C:\Windows\System32\cmd.exe`, found: true},
+		{key: "CommandLine", value: "cmd.exe /c whoami", found: true},
+		{key: "Args", value: []interface{}{"/c", "whoami"}, found: true},
+		{key: "Parent.Image", value: `C:\Windows\explorer.exe`, found: true},
+		{key: "Parent.Parent.Image"},
+		{key: "Labels.env", value: "prod", found: true},
+		{key: "Labels.missing"},
+		{key: "Secret"},
+		{key: "internal"},
+		{key: "Name"},
+	} {
+		val, ok := e.GetField(c.key)
+		if ok != c.found || !reflect.DeepEqual(val, c.value) {
+			t.Fatalf("%s: expected %#v %v, got %#v %v", c.key, c.value, c.found, val, ok)
+		}
+	}
+	if msg := e.GetMessage(); !reflect.DeepEqual(msg, []string{`C:\Windows\System32\cmd.exe`, "cmd.exe /c whoami"}) {
+		t.Fatalf("wrong message %+v", msg)
+	}
+
+	tree, err := ParseDetection(Detection{
+		"condition": "selection",
+		"selection": map[string]interface{}{
+			"PID":          4242,
+			"Parent.Image": `*\explorer.exe`,
+			"CommandLine":  "whoami",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tree.Match(e) {
+		t.Fatal("rule did not match struct event")
+	}
+	if e2, _ := NewStructEvent(*p); e2.schema != e.schema {
+		t.Fatal("schema should be cached per type")
+	}
+	if _, err := NewStructEvent((*testProcess)(nil)); err == nil {
+		t.Fatal("nil pointer should return error")
+	}
+	if _, err := NewStructEvent("string"); err == nil {
+		t.Fatal("non-struct value should return error")
+	}
+}
+
+func BenchmarkStructEvent(b *testing.B) {
+	p := &testProcess{Image: `C:\Windows\System32\cmd.exe`, Parent: &testProcess{Image: `C:\Windows\explorer.exe`}}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		e, _ := NewStructEvent(p)
+		e.GetField("Parent.Image")
+	}
+}
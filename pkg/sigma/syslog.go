@@ -0,0 +1,232 @@
+package sigma
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SyslogEvent implements EventChecker for RFC 5424 and RFC 3164 syslog lines
+// Header fields are exposed as facility, severity, priority, version, timestamp, host, app_name, procid and msgid
+// Structured data params are exposed as sdid.param and as plain param names if not shadowed by header
+// Plain param names shared by several SD-IDs resolve to the first SD-ID in message order
+// MSG part is exposed as message field and returned by GetMessage for keyword rules
+type SyslogEvent struct {
+	HasPriority        bool
+	Priority           int
+	Facility, Severity int
+	// Version is 0 for RFC 3164 messages
+	Version   int
+	Timestamp string
+	Host      string
+	AppName   string
+	ProcID    string
+	MsgID     string
+	// StructuredData maps SD-ID to its params
+	StructuredData map[string]map[string]string
+	// SDIDs lists keys of StructuredData in message order
+	SDIDs   []string
+	Message string
+}
+
+// ParseSyslog parses a single syslog line, priority header is optional for lines that are read from files
+func ParseSyslog(line string) (*SyslogEvent, error) {
+	s := &SyslogEvent{}
+	line = strings.TrimRight(line, "\r\n")
+	if strings.HasPrefix(line, "<") {
+		end := strings.IndexByte(line, '>')
+		if end < 2 || end > 4 {
+			return nil, fmt.Errorf("invalid syslog priority in %s", line)
+		}
+		pri, err := strconv.Atoi(line[1:end])
+		if err != nil || pri > 191 {
+			return nil, fmt.Errorf("invalid syslog priority in %s", line)
+		}
+		s.HasPriority = true
+		s.Priority, s.Facility, s.Severity = pri, pri/8, pri%8
+		line = line[end+1:]
+	}
+	if i := strings.IndexByte(line, ' '); i > 0 && s.HasPriority {
+		if v, err := strconv.Atoi(line[:i]); err == nil && v > 0 {
+			s.Version = v
+			return s, s.parse5424(line[i+1:])
+		}
+	}
+	return s, s.parse3164(line)
+}
+
+func (s *SyslogEvent) parse5424(line string) error {
+	header := make([]string, 5)
+	for i := range header {
+		var val string
+		if end := strings.IndexByte(line, ' '); end >= 0 {
+			val, line = line[:end], line[end+1:]
+		} else if i == len(header)-1 {
+			val, line = line, ""
+		} else {
+			return fmt.Errorf("truncated RFC 5424 header")
+		}
+		if val != "-" {
+			header[i] = val
+		}
+	}
+	s.Timestamp, s.Host, s.AppName, s.ProcID, s.MsgID = header[0], header[1], header[2], header[3], header[4]
+
+	switch {
+	case strings.HasPrefix(line, "-"):
+		line = line[1:]
+	case strings.HasPrefix(line, "["):
+		rest, err := s.parseStructuredData(line)
+		if err != nil {
+			return err
+		}
+		line = rest
+	}
+	s.Message = strings.TrimPrefix(strings.TrimPrefix(line, " "), "\xEF\xBB\xBF")
+	return nil
+}
+
+// parseStructuredData consumes SD-ELEMENTs and returns the remainder of the line
+func (s *SyslogEvent) parseStructuredData(line string) (string, error) {
+	s.StructuredData = make(map[string]map[string]string)
+	for strings.HasPrefix(line, "[") {
+		line = line[1:]
+		end := strings.IndexAny(line, " ]")
+		if end < 1 {
+			return line, fmt.Errorf("invalid structured data element")
+		}
+		id := line[:end]
+		params := make(map[string]string)
+		if _, ok := s.StructuredData[id]; !ok {
+			s.SDIDs = append(s.SDIDs, id)
+		}
+		s.StructuredData[id] = params
+		line = line[end:]
+		for {
+			line = strings.TrimLeft(line, " ")
+			if strings.HasPrefix(line, "]") {
+				line = line[1:]
+				break
+			}
+			eq := strings.Index(line, `="`)
+			if eq < 1 {
+				return line, fmt.Errorf("invalid structured data param in %s", id)
+			}
+			name := line[:eq]
+			line = line[eq+2:]
+			var (
+				val    strings.Builder
+				closed bool
+			)
+			for i := 0; i < len(line); i++ {
+				c := line[i]
+				if c == '\\' && i+1 < len(line) {
+					switch line[i+1] {
+					case '"', '\\', ']':
+						val.WriteByte(line[i+1])
+						i++
+						continue
+					}
+				}
+				if c == '"' {
+					line = line[i+1:]
+					closed = true
+					break
+				}
+				val.WriteByte(c)
+			}
+			if !closed {
+				return line, fmt.Errorf("unterminated structured data param %s in %s", name, id)
+			}
+			params[name] = val.String()
+		}
+	}
+	return line, nil
+}
+
+var syslogMonths = []string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"}
+
+func (s *SyslogEvent) parse3164(line string) error {
+	isBSDTime := false
+	for _, m := range syslogMonths {
+		if strings.HasPrefix(line, m+" ") {
+			isBSDTime = true
+			break
+		}
+	}
+	switch {
+	case isBSDTime && len(line) >= 15:
+		s.Timestamp, line = line[:15], strings.TrimLeft(line[15:], " ")
+	case len(line) > 0 && line[0] >= '0' && line[0] <= '9':
+		// high precision timestamps written by rsyslog and syslog-ng
+		if i := strings.IndexByte(line, ' '); i > 0 {
+			s.Timestamp, line = line[:i], line[i+1:]
+		}
+	}
+	if i := strings.IndexByte(line, ' '); i > 0 && s.Timestamp != "" {
+		if token := line[:i]; !strings.HasSuffix(token, ":") && !strings.Contains(token, "[") {
+			s.Host, line = token, line[i+1:]
+		}
+	}
+	// TAG is terminated by colon or PID in brackets
+	if i := strings.IndexAny(line, ":[ "); i > 0 && line[i] != ' ' {
+		s.AppName = line[:i]
+		rest := line[i:]
+		if rest[0] == '[' {
+			if end := strings.IndexByte(rest, ']'); end > 0 {
+				s.ProcID = rest[1:end]
+				rest = rest[end+1:]
+			}
+		}
+		if strings.HasPrefix(rest, ":") {
+			line = strings.TrimPrefix(rest[1:], " ")
+		} else {
+			// not a tag after all
+			s.AppName, s.ProcID = "", ""
+		}
+	}
+	s.Message = line
+	return nil
+}
+
+// GetMessage implements MessageGetter
+func (s SyslogEvent) GetMessage() []string { return []string{s.Message} }
+
+// GetField implements SelectionGetter
+func (s SyslogEvent) GetField(key string) (interface{}, bool) {
+	switch key {
+	case "facility":
+		return s.Facility, s.HasPriority
+	case "severity":
+		return s.Severity, s.HasPriority
+	case "priority":
+		return s.Priority, s.HasPriority
+	case "version":
+		return s.Version, true
+	case "timestamp":
+		return s.Timestamp, s.Timestamp != ""
+	case "host":
+		return s.Host, s.Host != ""
+	case "app_name":
+		return s.AppName, s.AppName != ""
+	case "procid":
+		return s.ProcID, s.ProcID != ""
+	case "msgid":
+		return s.MsgID, s.MsgID != ""
+	case "message":
+		return s.Message, true
+	}
+	for _, id := range s.SDIDs {
+		if strings.HasPrefix(key, id+".") {
+			if val, ok := s.StructuredData[id][key[len(id)+1:]]; ok {
+				return val, true
+			}
+		}
+	}
+	for _, id := range s.SDIDs {
+		if val, ok := s.StructuredData[id][key]; ok {
+			return val, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,132 @@
+package sigma
+
+import (
+	"testing"
+)
+
+type syslogCase struct {
+	line    string
+	fields  map[string]interface{}
+	missing []string
+	message string
+}
+
+var syslogCases = []syslogCase{
+	{
+		line: `<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="Application" eventID="1011"][origin ip="192.0.2.1" note="a \"quoted\] value"] BOMAn application event log entry`,
+		fields: map[string]interface{}{
+			"priority":                      165,
+			"facility":                      20,
+			"severity":                      5,
+			"version":                       1,
+			"timestamp":                     "2003-10-11T22:14:15.003Z",
+			"host":                          "mymachine.example.com",
+			"app_name":                      "evntslog",
+			"msgid":                         "ID47",
+			"exampleSDID@32473.eventSource": "Application",
+			"eventID":                       "1011",
+			"origin.ip":                     "192.0.2.1",
+			"note":                          `a "quoted] value`,
+		},
+		missing: []string{"procid", "origin.iut"},
+		message: "BOMAn application event log entry",
+	},
+	{
+		line: `<34>1 2003-10-11T22:14:15.003Z mymachine.example.com su 123 - - 'su root' failed for lonvick on /dev/pts/8`,
+		fields: map[string]interface{}{
+			"facility": 4,
+			"severity": 2,
+			"app_name": "su",
+			"procid":   "123",
+		},
+		missing: []string{"msgid"},
+		message: "'su root' failed for lonvick on /dev/pts/8",
+	},
+	{
+		line: `<38>Jan  6 10:12:01 server1 sshd[4242]: Accepted publickey for root from 10.0.0.1 port 53122 ssh2`,
+		fields: map[string]interface{}{
+			"facility":  4,
+			"severity":  6,
+			"version":   0,
+			"timestamp": "Jan  6 10:12:01",
+			"host":      "server1",
+			"app_name":  "sshd",
+			"procid":    "4242",
+		},
+		message: "Accepted publickey for root from 10.0.0.1 port 53122 ssh2",
+	},
+	{
+		line: `Jan 16 10:00:00 web01 kernel: device eth0 entered promiscuous mode`,
+		fields: map[string]interface{}{
+			"host":     "web01",
+			"app_name": "kernel",
+		},
+		missing: []string{"facility", "procid"},
+		message: "device eth0 entered promiscuous mode",
+	},
+	{
+		line: `2020-01-16T10:00:00.123456+02:00 web01 sudo:    alice : TTY=pts/0 ; PWD=/home/alice ; USER=root ; COMMAND=/bin/bash`,
+		fields: map[string]interface{}{
+			"timestamp": "2020-01-16T10:00:00.123456+02:00",
+			"host":      "web01",
+			"app_name":  "sudo",
+		},
+		message: "   alice : TTY=pts/0 ; PWD=/home/alice ; USER=root ; COMMAND=/bin/bash",
+	},
+}
+
+func TestSyslog(t *testing.T) {
+	for _, c := range syslogCases {
+		s, err := ParseSyslog(c.line)
+		if err != nil {
+			t.Fatalf("%s: %s", c.line, err)
+		}
+		for k, v := range c.fields {
+			if val, ok := s.GetField(k); !ok || val != v {
+				t.Fatalf("%s: %s expected %v, got %v", c.line, k, v, val)
+			}
+		}
+		for _, k := range c.missing {
+			if val, ok := s.GetField(k); ok {
+				t.Fatalf("%s: %s should be missing, got %v", c.line, k, val)
+			}
+		}
+		if msg := s.GetMessage(); len(msg) != 1 || msg[0] != c.message {
+			t.Fatalf("%s: expected message %q, got %q", c.line, c.message, msg)
+		}
+	}
+	for _, line := range []string{
+		`<999>1 2003-10-11T22:14:15.003Z host app - - -`,
+		`<34>1 2003-10-11T22:14:15.003Z`,
+		`<34>1 2003-10-11T22:14:15.003Z host app - - [id key="unterminated]`,
+	} {
+		if _, err := ParseSyslog(line); err == nil {
+			t.Fatalf("%s should return error", line)
+		}
+	}
+
+	kw, err := NewKeyword(false, "*promiscuous mode*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := ParseSyslog(syslogCases[3].line)
+	if !kw.Match(s) {
+		t.Fatal("keyword rule did not match syslog message")
+	}
+}
+
+func TestSyslogSharedParam(t *testing.T) {
+	s, err := ParseSyslog(`<165>1 2003-10-11T22:14:15.003Z host app - - [origin ip="192.0.2.1"][meta ip="198.51.100.7" seq="1"] msg`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// map iteration order is random, so repeat lookups to catch nondeterministic resolution
+	for i := 0; i < 32; i++ {
+		if val, ok := s.GetField("ip"); !ok || val != "192.0.2.1" {
+			t.Fatalf("shared param should resolve to first SD-ID, got %v", val)
+		}
+		if val, ok := s.GetField("meta.ip"); !ok || val != "198.51.100.7" {
+			t.Fatalf("qualified param should resolve to its SD-ID, got %v", val)
+		}
+	}
+}
@@ -25,6 +25,13 @@ type EventChecker interface {
 	SelectionGetter
 }
 
+// LogsourceGetter is implemented by events that can describe their own logsource
+// Such events can be routed to applicable rules with Ruleset.CheckEvent
+type LogsourceGetter interface {
+	// GetLogsource returns product, category and service of the event
+	GetLogsource() Logsource
+}
+
 // Matcher represents either left or right branch of AST matching tree
 type Matcher interface {
 	// Match implements sigma Matcher
@@ -40,17 +47,52 @@ func (e ErrInvalidRegex) Error() string {
 	return fmt.Sprintf("/%s/ %s", e.Pattern, e.Err)
 }
 
+// ErrInvalidID is returned for rules that do not carry a valid UUID in ID field
+type ErrInvalidID struct{ ID string }
+
+func (e ErrInvalidID) Error() string {
+	if e.ID == "" {
+		return "sigma rule is missing ID"
+	}
+	return fmt.Sprintf("invalid rule ID %s, should be UUID", e.ID)
+}
+
+// ErrDuplicateID is returned for rules that conflict with another rule with identical ID
+type ErrDuplicateID struct {
+	ID    string
+	Paths []string
+}
+
+func (e ErrDuplicateID) Error() string {
+	return fmt.Sprintf("duplicate rule ID %s in %s", e.ID, strings.Join(e.Paths, ", "))
+}
+
+// ErrUnmappedFields is returned for rules that reference fields without mapping in pipeline
+type ErrUnmappedFields struct {
+	Pipeline string
+	Fields   []string
+}
+
+func (e ErrUnmappedFields) Error() string {
+	return fmt.Sprintf("pipeline %s has no mapping for fields %s", e.Pipeline, strings.Join(e.Fields, ", "))
+}
+
 type RawRule struct {
 	File string `yaml:"file" json:"file"`
 
 	// https://github.com/Neo23x0/sigma/wiki/Specification
 	ID          string `yaml:"id" json:"id"`
+	Name        string `yaml:"name" json:"name"`
 	Title       string `yaml:"title" json:"title"`
 	Status      string `yaml:"status" json:"status"`
 	Description string `yaml:"description" json:"description"`
 	Author      string `yaml:"author" json:"author"`
+	// Creation and modification dates in YYYY/MM/DD format
+	Date     string `yaml:"date" json:"date"`
+	Modified string `yaml:"modified" json:"modified"`
 	// A list of URL-s to external sources
 	References []string  `yaml:"references" json:"references"`
+	Related    []Related `yaml:"related" json:"related"`
 	Logsource  Logsource `yaml:"logsource" json:"logsource"`
 
 	Detection Detection `yaml:"detection" json:"detection"`
@@ -59,6 +101,15 @@ type RawRule struct {
 	Falsepositives interface{} `yaml:"falsepositives" json:"falsepositives"`
 	Level          interface{} `yaml:"level" json:"level"`
 	Tags           Tags        `yaml:"tags" json:"tags"`
+
+	// Custom holds any non-standard attributes
+	Custom map[string]interface{} `yaml:",inline" json:"custom,omitempty"`
+}
+
+// Related references another rule
+type Related struct {
+	ID   string `yaml:"id" json:"id"`
+	Type string `yaml:"type" json:"type"`
 }
 
 func (r RawRule) Condition() (string, error) {
@@ -85,12 +136,43 @@ type Logsource struct {
 	Definition string `yaml:"definition" json:"definition"`
 }
 
+// key normalizes logsource for routing, definition is free-form text and is not used
+func (l Logsource) key() Logsource {
+	return Logsource{
+		Product:  strings.ToLower(strings.TrimSpace(l.Product)),
+		Category: strings.ToLower(strings.TrimSpace(l.Category)),
+		Service:  strings.ToLower(strings.TrimSpace(l.Service)),
+	}
+}
+
 type Tags []string
 
 type Result struct {
 	Tags Tags
 
 	ID, Title string
+
+	Level          Level
+	Status         Status
+	Description    string
+	References     []string
+	Falsepositives []string
+	Logsource      Logsource
+	// Path of rule file
+	Path string
+
+	// Selections are detection identifiers that made the event match
+	// Only collected if Config.MatchDetails is enabled
+	Selections []MatchedSelection `json:",omitempty"`
+}
+
+// MatchedSelection holds a detection identifier and event values that matched it
+type MatchedSelection struct {
+	Name string
+	// Fields maps selection field names to event values
+	Fields map[string]interface{} `json:",omitempty"`
+	// Keywords are event messages matched by keyword identifier
+	Keywords []string `json:",omitempty"`
 }
 
 type Results []Result
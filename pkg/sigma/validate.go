@@ -0,0 +1,310 @@
+package sigma
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Level is the severity of a sigma rule
+type Level int
+
+const (
+	LevelUnknown Level = iota
+	LevelInformational
+	LevelLow
+	LevelMedium
+	LevelHigh
+	LevelCritical
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelInformational:
+		return "informational"
+	case LevelLow:
+		return "low"
+	case LevelMedium:
+		return "medium"
+	case LevelHigh:
+		return "high"
+	case LevelCritical:
+		return "critical"
+	default:
+		return ""
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (l Level) MarshalText() ([]byte, error) { return []byte(l.String()), nil }
+
+// ParseLevel is the inverse of Level.String
+func ParseLevel(s string) (Level, bool) {
+	for l := LevelInformational; l <= LevelCritical; l++ {
+		if l.String() == s {
+			return l, true
+		}
+	}
+	return LevelUnknown, false
+}
+
+// Status is the maturity of a sigma rule
+type Status int
+
+const (
+	StatusUnknown Status = iota
+	StatusStable
+	StatusTest
+	StatusExperimental
+	StatusDeprecated
+	StatusUnsupported
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusStable:
+		return "stable"
+	case StatusTest:
+		return "test"
+	case StatusExperimental:
+		return "experimental"
+	case StatusDeprecated:
+		return "deprecated"
+	case StatusUnsupported:
+		return "unsupported"
+	default:
+		return ""
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (s Status) MarshalText() ([]byte, error) { return []byte(s.String()), nil }
+
+// ParseStatus is the inverse of Status.String
+func ParseStatus(s string) (Status, bool) {
+	for st := StatusStable; st <= StatusUnsupported; st++ {
+		if st.String() == s {
+			return st, true
+		}
+	}
+	return StatusUnknown, false
+}
+
+var relatedTypes = map[string]bool{
+	"derived":   true,
+	"obsoletes": true,
+	"merged":    true,
+	"renamed":   true,
+	"similar":   true,
+}
+
+var tagRegex = regexp.MustCompile(`^[a-z0-9_-]+\.[a-z0-9._-]+$`)
+
+// Metadata holds typed rule attributes that are loosely defined in RawRule
+type Metadata struct {
+	Level          Level
+	Status         Status
+	Date, Modified time.Time
+	Related        []Related
+	Fields         []string
+	Falsepositives []string
+}
+
+// SpecViolation describes a single deviation from sigma specification
+type SpecViolation struct {
+	Field string
+	Msg   string
+}
+
+func (s SpecViolation) String() string { return fmt.Sprintf("%s: %s", s.Field, s.Msg) }
+
+// ErrSpecViolations is returned for rules that do not conform to sigma specification
+type ErrSpecViolations []SpecViolation
+
+func (e ErrSpecViolations) Error() string {
+	msgs := make([]string, len(e))
+	for i, v := range e {
+		msgs[i] = v.String()
+	}
+	return fmt.Sprintf("sigma specification violations: %s", strings.Join(msgs, "; "))
+}
+
+// Validate checks rule against sigma specification
+// Typed metadata is populated from every attribute that could be parsed, even if violations are found
+func (r RawRule) Validate() (Metadata, []SpecViolation) {
+	var (
+		m   Metadata
+		out = make([]SpecViolation, 0)
+	)
+	violation := func(field, format string, args ...interface{}) {
+		out = append(out, SpecViolation{Field: field, Msg: fmt.Sprintf(format, args...)})
+	}
+
+	switch {
+	case r.Title == "":
+		violation("title", "missing")
+	case len(r.Title) > 256:
+		violation("title", "longer than 256 characters")
+	}
+	if r.ID != "" && !isUUID(r.ID) {
+		violation("id", "%s is not a UUID", r.ID)
+	}
+	if r.Status != "" {
+		if st, ok := ParseStatus(r.Status); ok {
+			m.Status = st
+		} else {
+			violation("status", "unknown status %s", r.Status)
+		}
+	}
+	switch v := r.Level.(type) {
+	case nil:
+	case string:
+		if l, ok := ParseLevel(v); ok {
+			m.Level = l
+		} else {
+			violation("level", "unknown level %s", v)
+		}
+	default:
+		violation("level", "should be a string, got %T", v)
+	}
+
+	var err error
+	if r.Date != "" {
+		if m.Date, err = parseRuleDate(r.Date); err != nil {
+			violation("date", "%s", err)
+		}
+	}
+	if r.Modified != "" {
+		if m.Modified, err = parseRuleDate(r.Modified); err != nil {
+			violation("modified", "%s", err)
+		} else if !m.Date.IsZero() && m.Modified.Before(m.Date) {
+			violation("modified", "%s is before creation date %s", r.Modified, r.Date)
+		}
+	}
+
+	for i, rel := range r.Related {
+		if !isUUID(rel.ID) {
+			violation("related", "entry %d id %s is not a UUID", i, rel.ID)
+			continue
+		}
+		if !relatedTypes[rel.Type] {
+			violation("related", "entry %d has unknown type %s", i, rel.Type)
+			continue
+		}
+		m.Related = append(m.Related, rel)
+	}
+
+	if m.Fields, err = stringList(r.Fields); err != nil {
+		violation("fields", "%s", err)
+	}
+	if m.Falsepositives, err = stringList(r.Falsepositives); err != nil {
+		violation("falsepositives", "%s", err)
+	}
+	for _, tag := range r.Tags {
+		if !tagRegex.MatchString(tag) {
+			violation("tags", "%s should be lowercase namespace.value", tag)
+		}
+	}
+
+	if ls := r.Logsource.key(); ls.Product == "" && ls.Category == "" && ls.Service == "" {
+		violation("logsource", "should define product, category or service")
+	}
+	switch {
+	case r.Detection == nil || len(r.Detection) == 0:
+		violation("detection", "missing")
+	case r.GetCondition() == "":
+		violation("detection", "condition missing or not a string value")
+	}
+	return m, out
+}
+
+// parseRuleDate accepts both YYYY/MM/DD from specification and YYYY-MM-DD that is common in the wild
+func parseRuleDate(s string) (time.Time, error) {
+	if t, err := time.Parse("2006/01/02", s); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%s is not in YYYY/MM/DD format", s)
+}
+
+// stringList converts yaml value that may be a single string or a list of strings
+func stringList(v interface{}) ([]string, error) {
+	switch data := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{data}, nil
+	case []string:
+		return data, nil
+	case []interface{}:
+		out := make([]string, 0, len(data))
+		for _, item := range data {
+			str, ok := item.(string)
+			if !ok {
+				return out, fmt.Errorf("list item %v should be a string, got %T", item, item)
+			}
+			out = append(out, str)
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("should be a list of strings, got %T", v)
+	}
+}
+
+// LintResult holds specification violations for a single rule file
+type LintResult struct {
+	Path       string
+	Violations []SpecViolation
+}
+
+// Lint validates every rule file in configured directories against sigma specification and compiles it
+// like NewRuleset, with configured pipelines and regex limits
+// Unlike NewRuleset, it does not stop on files that cannot be decoded
+func Lint(c *Config) ([]LintResult, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	pipelines, err := ResolvePipelines(c.Mappings, c.Pipelines...)
+	if err != nil {
+		return nil, err
+	}
+	files, err := discoverRuleFilesInDir(c.Directories)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]LintResult, 0)
+	for _, path := range files {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		res := LintResult{Path: path}
+		if bytes.Contains(data, []byte("---")) {
+			res.Violations = []SpecViolation{{Field: "file", Msg: "multi-part YAML is not supported"}}
+			out = append(out, res)
+			continue
+		}
+		var raw RawRule
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			res.Violations = []SpecViolation{{Field: "file", Msg: err.Error()}}
+			out = append(out, res)
+			continue
+		}
+		_, violations, err := compileRule(raw, path, pipelines, c.RegexLimits, false)
+		res.Violations = violations
+		if err != nil {
+			res.Violations = append(res.Violations, SpecViolation{Field: "detection", Msg: err.Error()})
+		}
+		if len(res.Violations) > 0 {
+			out = append(out, res)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,128 @@
+package sigma
+
+import (
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+var validRule = `
+title: Whoami execution
+id: 5f1abf38-3f4d-4bd6-b8e2-6d4b1d8e0a01
+status: experimental
+description: Detects whoami
+author: test
+date: 2020/01/15
+modified: 2020-02-01
+related:
+    - id: 5f1abf38-3f4d-4bd6-b8e2-6d4b1d8e0a02
+      type: derived
+logsource:
+    category: process_creation
+detection:
+    selection:
+        CommandLine: whoami
+    condition: selection
+fields:
+    - CommandLine
+falsepositives: Admin activity
+level: high
+tags:
+    - attack.discovery
+    - attack.t1033
+custom_attribute: some value
+`
+
+var invalidRule = `
+title: Whoami execution
+id: 1234
+status: production
+date: 15.01.2020
+modified: 2019/01/01
+related:
+    - id: 5f1abf38-3f4d-4bd6-b8e2-6d4b1d8e0a02
+      type: copied
+logsource:
+    definition: none
+detection:
+    selection:
+        CommandLine: whoami
+fields: CommandLine
+falsepositives:
+    - 1
+level: severe
+tags:
+    - Attack.T1033
+`
+
+func TestValidate(t *testing.T) {
+	var raw RawRule
+	if err := yaml.Unmarshal([]byte(validRule), &raw); err != nil {
+		t.Fatal(err)
+	}
+	meta, violations := raw.Validate()
+	if len(violations) > 0 {
+		t.Fatalf("valid rule reported violations: %+v", violations)
+	}
+	if meta.Level != LevelHigh || meta.Status != StatusExperimental {
+		t.Fatalf("wrong level or status: %+v", meta)
+	}
+	if !meta.Date.Equal(time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC)) ||
+		!meta.Modified.Equal(time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("wrong dates: %+v", meta)
+	}
+	if len(meta.Related) != 1 || len(meta.Fields) != 1 || len(meta.Falsepositives) != 1 {
+		t.Fatalf("wrong lists: %+v", meta)
+	}
+	if raw.Custom["custom_attribute"] != "some value" {
+		t.Fatalf("custom attribute not decoded: %+v", raw.Custom)
+	}
+
+	raw = RawRule{}
+	if err := yaml.Unmarshal([]byte(invalidRule), &raw); err != nil {
+		t.Fatal(err)
+	}
+	_, violations = raw.Validate()
+	found := make(map[string]bool)
+	for _, v := range violations {
+		found[v.Field] = true
+	}
+	for _, field := range []string{
+		"id", "status", "date", "related", "logsource", "detection", "falsepositives", "level", "tags",
+	} {
+		if !found[field] {
+			t.Fatalf("expected violation for %s, got %+v", field, violations)
+		}
+	}
+}
+
+func TestRulesetStrictSchema(t *testing.T) {
+	dirs, cleanup := newTestRuleDirs(t, []testRuleFile{
+		{name: "a.yml", id: testID1, title: "valid", pattern: "a"},
+		{name: "b.yml", id: testID2, title: "", pattern: "b"},
+	})
+	defer cleanup()
+
+	r, err := NewRuleset(&Config{Directories: dirs, StrictSchema: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Total != 1 || len(r.Broken) != 1 {
+		t.Fatalf("expected 1 valid and 1 broken rule, got %d and %d", r.Total, len(r.Broken))
+	}
+	if _, ok := r.Broken[0].Error.(ErrSpecViolations); !ok {
+		t.Fatalf("expected ErrSpecViolations, got %+v", r.Broken[0].Error)
+	}
+	if rule := r.Rules["windows"][0]; rule.Metadata.Level != LevelHigh || rule.Metadata.Status != StatusExperimental {
+		t.Fatalf("metadata not populated: %+v", rule.Metadata)
+	}
+
+	res, err := Lint(&Config{Directories: dirs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0].Violations[0].Field != "title" {
+		t.Fatalf("expected title violation from lint, got %+v", res)
+	}
+}
@@ -0,0 +1,192 @@
+package sigma
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Visitor is called for every node by Walk, modeled after go/ast
+// If returned visitor w is not nil, Walk visits each child of the node with w, followed by w.Visit(nil)
+type Visitor interface {
+	Visit(b Branch) (w Visitor)
+}
+
+// Walk traverses tree in depth first order
+// Tree, NodeSimpleAnd, NodeSimpleOr, NodeAnd, NodeOr, NodeNot and FieldsList have children, other branches are leaves
+func Walk(v Visitor, b Branch) {
+	if v = v.Visit(b); v == nil {
+		return
+	}
+	for _, child := range children(b) {
+		Walk(v, child)
+	}
+	v.Visit(nil)
+}
+
+type inspector func(Branch) bool
+
+func (f inspector) Visit(b Branch) Visitor {
+	if f(b) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses tree in depth first order, children are skipped if f returns false
+func Inspect(b Branch, f func(Branch) bool) {
+	Walk(inspector(f), b)
+}
+
+func children(b Branch) []Branch {
+	switch n := b.(type) {
+	case Tree:
+		return []Branch{n.Root}
+	case *Tree:
+		return []Branch{n.Root}
+	case NodeSimpleAnd:
+		return n
+	case NodeSimpleOr:
+		return n
+	case NodeAnd:
+		return []Branch{n.L, n.R}
+	case NodeOr:
+		return []Branch{n.L, n.R}
+	case NodeNot:
+		return []Branch{n.Branch}
+	case FieldsList:
+		out := make([]Branch, len(n))
+		for i, f := range n {
+			out[i] = f
+		}
+		return out
+	}
+	return nil
+}
+
+// String renders tree as sigma condition
+func (t Tree) String() string { return FormatCondition(t.Root) }
+
+// FormatCondition renders a branch as sigma condition using detection identifier names
+// Composite operands other than negation are always enclosed in parentheses, so the condition parses back into equivalent tree
+// when combined with original detection
+func FormatCondition(b Branch) string {
+	b = unwrap(b)
+	switch n := b.(type) {
+	case NodeSimpleAnd:
+		return formatOperands(" and ", n...)
+	case NodeSimpleOr:
+		return formatOperands(" or ", n...)
+	case NodeAnd:
+		return formatOperands(" and ", n.L, n.R)
+	case NodeOr:
+		return formatOperands(" or ", n.L, n.R)
+	case NodeNot:
+		// negation binds tighter than and, but nested negation is kept explicit
+		if _, ok := unwrap(n.Branch).(NodeNot); ok {
+			return "not (" + FormatCondition(n.Branch) + ")"
+		}
+		return "not " + formatOperand(n.Branch)
+	case interface{ Ident() string }:
+		if id := n.Ident(); id != "" {
+			return id
+		}
+		return "<unnamed>"
+	}
+	return fmt.Sprintf("<%T>", b)
+}
+
+func formatOperands(op string, operands ...Branch) string {
+	out := make([]string, len(operands))
+	for i, b := range operands {
+		out[i] = formatOperand(b)
+	}
+	return strings.Join(out, op)
+}
+
+func formatOperand(b Branch) string {
+	b = unwrap(b)
+	switch b.(type) {
+	case NodeSimpleAnd, NodeSimpleOr, NodeAnd, NodeOr:
+		return "(" + FormatCondition(b) + ")"
+	}
+	return FormatCondition(b)
+}
+
+// unwrap replaces trees and single element nodes with their only child
+func unwrap(b Branch) Branch {
+	for {
+		switch n := b.(type) {
+		case Tree:
+			b = n.Root
+		case *Tree:
+			b = n.Root
+		case NodeSimpleAnd:
+			if len(n) != 1 {
+				return b
+			}
+			b = n[0]
+		case NodeSimpleOr:
+			if len(n) != 1 {
+				return b
+			}
+			b = n[0]
+		default:
+			return b
+		}
+	}
+}
+
+// DOT renders tree as Graphviz digraph
+func (t Tree) DOT(title string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %s {\n", strconv.Quote(title))
+	b.WriteString("\tnode [shape=box];\n")
+	id := 0
+	var node func(Branch) int
+	node = func(br Branch) int {
+		br = unwrap(br)
+		self := id
+		id++
+		label, shape := dotLabel(br)
+		fmt.Fprintf(&b, "\tn%d [label=%s shape=%s];\n", self, strconv.Quote(label), shape)
+		for _, child := range children(br) {
+			fmt.Fprintf(&b, "\tn%d -> n%d;\n", self, node(child))
+		}
+		return self
+	}
+	node(t.Root)
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func dotLabel(b Branch) (string, string) {
+	switch n := b.(type) {
+	case NodeSimpleAnd, NodeAnd:
+		return "and", "ellipse"
+	case NodeSimpleOr, NodeOr:
+		return "or", "ellipse"
+	case NodeNot:
+		return "not", "ellipse"
+	case FieldsList:
+		return n.Ident() + "\n(any of)", "box"
+	case *Fields:
+		fields := make([]string, 0, len(n.sPatterns)+len(n.nPatterns)+len(n.bPatterns)+len(n.nulls))
+		for field := range n.sPatterns {
+			fields = append(fields, field)
+		}
+		for field := range n.nPatterns {
+			fields = append(fields, field)
+		}
+		for field := range n.bPatterns {
+			fields = append(fields, field)
+		}
+		fields = append(fields, n.nulls...)
+		sort.Strings(fields)
+		return n.ident + "\n" + strings.Join(fields, "\n"), "box"
+	case *Keyword:
+		return n.ident + "\n" + strings.Join(n.patterns(), "\n"), "box"
+	}
+	return fmt.Sprintf("%T", b), "box"
+}
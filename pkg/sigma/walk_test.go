@@ -0,0 +1,153 @@
+package sigma
+
+import (
+	"strings"
+	"testing"
+)
+
+func walkTestDetection(condition string) Detection {
+	return Detection{
+		"sel1":      map[interface{}]interface{}{"a": "1"},
+		"sel2":      map[interface{}]interface{}{"b": "2"},
+		"sel3":      []interface{}{map[interface{}]interface{}{"c": "3"}, map[interface{}]interface{}{"c": "4"}},
+		"filter":    map[interface{}]interface{}{"d": "5"},
+		"condition": condition,
+	}
+}
+
+func TestFormatConditionRoundTrip(t *testing.T) {
+	events := []dummyObject{
+		{"a": "1", "b": "2"},
+		{"a": "1", "c": "4"},
+		{"a": "1", "c": "3", "d": "5"},
+		{"b": "2", "d": "5"},
+		{"b": "2"},
+		{},
+	}
+	for _, condition := range []string{
+		"sel1",
+		"not sel1",
+		"sel1 and sel2",
+		"sel1 or sel2 or sel3",
+		"sel1 and not filter or sel2",
+		"sel1 and (sel2 or sel3) and not filter",
+		"(sel1 or sel2) and not (filter or sel3)",
+		"not (sel1 and sel2) and sel3",
+		"(sel1 or sel3) and not filter",
+		"sel1 or not (sel2 and sel3)",
+		"sel1 and not (not sel2)",
+		"sel1 and not sel2 and (sel3 or filter)",
+		"(sel3 or filter) and not sel2 and sel1",
+	} {
+		tree, err := ParseDetection(walkTestDetection(condition))
+		if err != nil {
+			t.Fatalf("%s: %s", condition, err)
+		}
+		out := tree.String()
+		parsed, err := ParseDetection(walkTestDetection(out))
+		if err != nil {
+			t.Fatalf("%s rendered as %s: %s", condition, out, err)
+		}
+		if parsed.String() != out {
+			t.Fatalf("%s rendered as %s, but reparsed as %s", condition, out, parsed.String())
+		}
+		for _, e := range events {
+			if tree.Match(e) != parsed.Match(e) {
+				t.Fatalf("%s and %s differ for %+v", condition, out, e)
+			}
+		}
+	}
+	tree, _ := ParseDetection(walkTestDetection("sel1 and not filter or sel2"))
+	if s := tree.String(); s != "(sel1 and not filter) or sel2" {
+		t.Fatalf("unexpected condition %s", s)
+	}
+}
+
+type countVisitor map[string]int
+
+func (c countVisitor) Visit(b Branch) Visitor {
+	switch b.(type) {
+	case nil:
+		c["exit"]++
+	case *Fields:
+		c["fields"]++
+	case NodeNot:
+		c["not"]++
+	}
+	return c
+}
+
+func TestWalk(t *testing.T) {
+	tree, err := ParseDetection(walkTestDetection("(sel1 or sel3) and not filter"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := countVisitor{}
+	Walk(c, tree)
+	if c["fields"] != 4 || c["not"] != 1 {
+		t.Fatalf("wrong node counts %+v", c)
+	}
+	idents := make([]string, 0)
+	Inspect(tree, func(b Branch) bool {
+		if _, ok := b.(NodeNot); ok {
+			return false
+		}
+		if l, ok := b.(interface{ Ident() string }); ok {
+			idents = append(idents, l.Ident())
+			return false
+		}
+		return true
+	})
+	if strings.Join(idents, ",") != "sel1,sel3" {
+		t.Fatalf("negated branch should not be inspected, got %v", idents)
+	}
+	dot := tree.DOT("test")
+	for _, s := range []string{`digraph "test" {`, `label="not"`, `label="sel3\n(any of)"`, `label="filter\nd"`, "n0 -> n1;"} {
+		if !strings.Contains(dot, s) {
+			t.Fatalf("missing %s from\n%s", s, dot)
+		}
+	}
+}
+
+func TestFormatConditionPipelineConditions(t *testing.T) {
+	p, err := NewPipeline([]byte(testPipeline))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rule, _, err := compileRule(RawRule{
+		Logsource: Logsource{Product: "windows", Category: "process_creation"},
+		Detection: Detection{
+			"selection":            map[interface{}]interface{}{"CommandLine": "whoami"},
+			"pipeline_condition_1": map[interface{}]interface{}{"Image": "cmd.exe"},
+			"condition":            "selection or pipeline_condition_1",
+		},
+	}, "", []*Pipeline{p}, RegexLimits{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := rule.Source().String()
+	if out != "_pipeline_condition_1 and (selection or pipeline_condition_1)" {
+		t.Fatalf("unexpected condition %s", out)
+	}
+
+	// condition parses back when injected selections are added to detection
+	detection := Detection{"_pipeline_condition_1": map[interface{}]interface{}{"event.type": "start"}, "condition": out}
+	for k, v := range rule.Detection {
+		if k != "condition" {
+			detection[k] = v
+		}
+	}
+	parsed, err := ParseDetection(detection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range []dummyObject{
+		{"process.command_line": "whoami", "event.type": "start"},
+		{"process.executable": "cmd.exe", "event.type": "start"},
+		{"process.command_line": "whoami"},
+	} {
+		if rule.Source().Match(e) != parsed.Match(e) {
+			t.Fatalf("%s differs from rule for %+v", out, e)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package sigma
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ZeekEvent implements EventChecker and LogsourceGetter for a single Zeek log entry
+// Fields use Zeek names such as id.orig_h, logsource is product zeek with log path as service
+type ZeekEvent struct {
+	DynamicMap
+
+	// Path is the log stream name, for example conn, dns or http
+	Path string
+}
+
+// GetLogsource implements LogsourceGetter
+func (z ZeekEvent) GetLogsource() Logsource {
+	return Logsource{Product: "zeek", Service: z.Path}
+}
+
+// NewZeekJSON decodes a Zeek log entry written in JSON format
+// JSON logs do not carry their stream name, so path should be provided by the caller
+// _path field is used if path is empty
+func NewZeekJSON(data []byte, path string) (*ZeekEvent, error) {
+	m, err := NewDynamicMapFromJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		path, _ = m.Data["_path"].(string)
+	}
+	return &ZeekEvent{DynamicMap: *m, Path: path}, nil
+}
+
+// ZeekReader reads Zeek logs in default TSV format
+// Header directives for separators, path, fields and types are honored and may change mid-stream
+type ZeekReader struct {
+	scanner *bufio.Scanner
+
+	separator    string
+	setSeparator string
+	emptyField   string
+	unsetField   string
+	path         string
+	fields       []string
+	types        []string
+}
+
+// NewZeekReader wraps a Zeek TSV log stream
+func NewZeekReader(r io.Reader) *ZeekReader {
+	return &ZeekReader{
+		scanner:      bufio.NewScanner(r),
+		separator:    "\t",
+		setSeparator: ",",
+		emptyField:   "(empty)",
+		unsetField:   "-",
+	}
+}
+
+// Next returns the next log entry, io.EOF is returned when stream is exhausted
+func (z *ZeekReader) Next() (*ZeekEvent, error) {
+	for z.scanner.Scan() {
+		line := z.scanner.Text()
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			if err := z.directive(line); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if z.fields == nil {
+			return nil, fmt.Errorf("zeek log entry before #fields header")
+		}
+		values := strings.Split(line, z.separator)
+		if len(values) != len(z.fields) {
+			return nil, fmt.Errorf("zeek log entry has %d values, expected %d", len(values), len(z.fields))
+		}
+		data := make(map[string]interface{}, len(values))
+		for i, val := range values {
+			if val == z.unsetField {
+				continue
+			}
+			var typ string
+			if i < len(z.types) {
+				typ = z.types[i]
+			}
+			data[z.fields[i]] = z.convert(val, typ)
+		}
+		return &ZeekEvent{DynamicMap: DynamicMap{Data: data}, Path: z.path}, nil
+	}
+	if err := z.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+func (z *ZeekReader) directive(line string) error {
+	if strings.HasPrefix(line, "#separator ") {
+		sep, err := unescapeZeek(strings.TrimPrefix(line, "#separator "))
+		if err != nil {
+			return err
+		}
+		z.separator = sep
+		return nil
+	}
+	parts := strings.Split(line, z.separator)
+	args := parts[1:]
+	switch parts[0] {
+	case "#set_separator":
+		if len(args) > 0 {
+			z.setSeparator = args[0]
+		}
+	case "#empty_field":
+		if len(args) > 0 {
+			z.emptyField = args[0]
+		}
+	case "#unset_field":
+		if len(args) > 0 {
+			z.unsetField = args[0]
+		}
+	case "#path":
+		if len(args) > 0 {
+			z.path = args[0]
+		}
+	case "#fields":
+		z.fields = args
+	case "#types":
+		z.types = args
+	}
+	return nil
+}
+
+// convert decodes a value according to zeek type, numeric types are converted to float64 like in JSON logs
+func (z *ZeekReader) convert(val, typ string) interface{} {
+	if strings.HasPrefix(typ, "set[") || strings.HasPrefix(typ, "vector[") {
+		out := make([]interface{}, 0)
+		if val == z.emptyField {
+			return out
+		}
+		inner := typ[strings.IndexByte(typ, '[')+1 : len(typ)-1]
+		for _, item := range strings.Split(val, z.setSeparator) {
+			out = append(out, z.convert(item, inner))
+		}
+		return out
+	}
+	if val == z.emptyField {
+		return ""
+	}
+	switch typ {
+	case "count", "int", "port", "double", "interval", "time":
+		if f, err := strconv.ParseFloat(val, 64); err == nil {
+			return f
+		}
+	case "bool":
+		switch val {
+		case "T":
+			return true
+		case "F":
+			return false
+		}
+	}
+	return val
+}
+
+// unescapeZeek decodes \xNN escapes used in zeek header
+func unescapeZeek(s string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) && s[i+1] == 'x' {
+			n, err := strconv.ParseUint(s[i+2:i+4], 16, 8)
+			if err != nil {
+				return "", fmt.Errorf("invalid zeek escape in %s", s)
+			}
+			b.WriteByte(byte(n))
+			i += 3
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String(), nil
+}
+
+// SuricataEvent implements EventChecker and LogsourceGetter for Suricata EVE JSON records
+// Logsource is product suricata with event_type as service
+type SuricataEvent struct {
+	DynamicMap
+
+	EventType string
+}
+
+// GetLogsource implements LogsourceGetter
+func (s SuricataEvent) GetLogsource() Logsource {
+	return Logsource{Product: "suricata", Service: s.EventType}
+}
+
+// NewSuricataEvent decodes a single EVE JSON record
+func NewSuricataEvent(data []byte) (*SuricataEvent, error) {
+	var obj map[string]interface{}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, err
+	}
+	s := &SuricataEvent{DynamicMap: DynamicMap{Data: obj}}
+	s.EventType, _ = obj["event_type"].(string)
+	if s.EventType == "alert" {
+		s.MessageFields = []string{"alert.signature"}
+	}
+	return s, nil
+}
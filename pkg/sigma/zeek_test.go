@@ -0,0 +1,106 @@
+package sigma
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var zeekDNSLog = "#separator \\x09\n" +
+	"#set_separator\t,\n" +
+	"#empty_field\t(empty)\n" +
+	"#unset_field\t-\n" +
+	"#path\tdns\n" +
+	"#open\t2020-01-16-10-00-00\n" +
+	"#fields\tts\tuid\tid.orig_h\tid.orig_p\tid.resp_h\tid.resp_p\tproto\tquery\tqtype_name\trcode\tAA\tanswers\tTTLs\n" +
+	"#types\ttime\tstring\taddr\tport\taddr\tport\tenum\tstring\tstring\tcount\tbool\tvector[string]\tvector[interval]\n" +
+	"1579168800.123456\tCk3Zr71t3UIRNyBWk\t10.0.0.5\t53122\t10.0.0.1\t53\tudp\tevil.example.com\tTXT\t0\tF\tv=spf1,1.2.3.4\t60.0,120.0\n" +
+	"1579168801.000000\tCk3Zr71t3UIRNyBWm\t10.0.0.6\t53123\t10.0.0.1\t53\tudp\t(empty)\tA\t-\tT\t(empty)\t-\n" +
+	"#close\t2020-01-16-11-00-00\n"
+
+func TestZeekReader(t *testing.T) {
+	r := NewZeekReader(strings.NewReader(zeekDNSLog))
+	e, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range map[string]interface{}{
+		"ts":        1579168800.123456,
+		"id.orig_h": "10.0.0.5",
+		"id.resp_p": float64(53),
+		"query":     "evil.example.com",
+		"rcode":     float64(0),
+		"AA":        false,
+		"answers":   []interface{}{"v=spf1", "1.2.3.4"},
+		"TTLs":      []interface{}{60.0, 120.0},
+	} {
+		if val, ok := e.GetField(k); !ok || !reflect.DeepEqual(val, v) {
+			t.Fatalf("%s: expected %#v, got %#v", k, v, val)
+		}
+	}
+	if ls := e.GetLogsource(); ls.Product != "zeek" || ls.Service != "dns" {
+		t.Fatalf("wrong logsource %+v", ls)
+	}
+	e, err = r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val, ok := e.GetField("query"); !ok || val != "" {
+		t.Fatalf("empty field should be empty string, got %#v", val)
+	}
+	if _, ok := e.GetField("rcode"); ok {
+		t.Fatal("unset field should be missing")
+	}
+	if val, _ := e.GetField("answers"); !reflect.DeepEqual(val, []interface{}{}) {
+		t.Fatalf("empty set should be empty list, got %#v", val)
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+
+	r = NewZeekReader(strings.NewReader("#fields\ta\tb\n1\t2\t3\n"))
+	if _, err := r.Next(); err == nil {
+		t.Fatal("mismatched field count should return error")
+	}
+}
+
+func TestZeekSuricataRouting(t *testing.T) {
+	dirs, cleanup := newTestRuleDirs(t, []testRuleFile{
+		{name: "zeek.yml", title: "zeek", field: "query", pattern: "evil.example.com",
+			logsource: "    product: zeek\n    service: dns"},
+		{name: "suricata.yml", title: "suricata", field: "dns.rrname", pattern: "evil.example.com",
+			logsource: "    product: suricata\n    service: dns"},
+	})
+	defer cleanup()
+	r, err := NewRuleset(&Config{Directories: dirs})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zeek, err := NewZeekJSON([]byte(`{"_path": "dns", "ts": 1579168800.1, "id.orig_h": "10.0.0.5", "query": "evil.example.com"}`), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	suricata, err := NewSuricataEvent([]byte(`{"timestamp": "2020-01-16T10:00:00.000000+0000", "event_type": "dns", "src_ip": "10.0.0.5", "dns": {"type": "query", "rrname": "evil.example.com", "rrtype": "A"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for title, obj := range map[string]EventChecker{"zeek": zeek, "suricata": suricata} {
+		res, ok := r.CheckEvent(obj, false)
+		if !ok || len(res) != 1 || res[0].Title != title {
+			t.Fatalf("%s: expected single match, got %+v", title, res)
+		}
+	}
+	if _, ok := r.CheckEvent(dummyObject{"query": "evil.example.com"}, false); ok {
+		t.Fatal("event without logsource should not match")
+	}
+
+	alert, err := NewSuricataEvent([]byte(`{"event_type": "alert", "alert": {"signature": "ET POLICY curl User-Agent", "severity": 3}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg := alert.GetMessage(); len(msg) != 1 || msg[0] != "ET POLICY curl User-Agent" {
+		t.Fatalf("wrong alert message %+v", msg)
+	}
+}